@@ -0,0 +1,237 @@
+// Package analyzer provides a go/analysis analyzer that checks the bin
+// struct tags used by binio, so that mistakes are found by go vet
+// instead of while decoding.
+//
+// Only structs with at least one bin tag are checked.
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/KlemensWinter/go-binio/expr"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const binioPath = "github.com/KlemensWinter/go-binio"
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "bintag",
+	Doc:      "check bin struct tags of binio",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+type structField struct {
+	*ast.Field
+	Name string
+	Tag  string // the bin tag
+	Has  bool   // the field has a bin tag
+	Type types.Type
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	ins.Preorder([]ast.Node{(*ast.StructType)(nil)}, func(n ast.Node) {
+		checkStruct(pass, n.(*ast.StructType))
+	})
+	return nil, nil
+}
+
+func checkStruct(pass *analysis.Pass, st *ast.StructType) {
+	var (
+		fields []*structField
+		tagged bool
+	)
+	for _, f := range st.Fields.List {
+		var (
+			tag string
+			has bool
+		)
+		if f.Tag != nil {
+			if s, err := strconv.Unquote(f.Tag.Value); err == nil {
+				tag, has = reflect.StructTag(s).Lookup("bin")
+			}
+		}
+		tagged = tagged || has
+		typ := pass.TypesInfo.TypeOf(f.Type)
+		names := f.Names
+		if len(names) == 0 { // embedded
+			names = []*ast.Ident{{Name: embeddedName(f.Type)}}
+		}
+		for _, name := range names {
+			fields = append(fields, &structField{Field: f, Name: name.Name, Tag: tag, Has: has, Type: typ})
+		}
+	}
+	if !tagged {
+		return
+	}
+
+	index := make(map[string]int, len(fields))
+	for i, f := range fields {
+		if f.Name != "_" {
+			index[f.Name] = i
+		}
+	}
+
+	for i, f := range fields {
+		var tag *binio.Tag
+		if f.Has {
+			var err error
+			if tag, err = binio.ParseTag(f.Tag); err != nil {
+				pass.Reportf(f.pos(), "invalid bin tag of %s: %v", f.Name, err)
+				continue
+			}
+		}
+		checkKind(pass, f, tag)
+		if tag == nil {
+			continue
+		}
+
+		// ref checks the fields e refers to, of which the first decoded
+		// are decoded when e is evaluated
+		ref := func(key string, e expr.Expr, decoded int) {
+			for _, name := range expr.Fields(e) {
+				if name == "_parent" || name == "_root" {
+					continue
+				}
+				j, found := index[name]
+				switch {
+				case !found:
+					pass.Reportf(f.pos(), "bin tag of %s: %s refers to unknown field %s", f.Name, key, name)
+				case j >= decoded:
+					pass.Reportf(f.pos(), "bin tag of %s: %s refers to field %s which is decoded later", f.Name, key, name)
+				}
+			}
+		}
+		ref("size", tag.Size, i)
+		ref("if", tag.If, i)
+		ref("ptrs", tag.Ptrs, i)
+		ref("until", tag.Until, i)
+		ref("valid", tag.Valid, i+1)
+		ref("offset", tag.Offset, len(fields))
+		for _, name := range sortedVars(tag) {
+			ref("$"+name, tag.Vars[name], i)
+		}
+	}
+}
+
+// pos returns the position of the tag or of the field if it has none.
+func (f *structField) pos() token.Pos {
+	if f.Field.Tag != nil {
+		return f.Field.Tag.Pos()
+	}
+	return f.Field.Pos()
+}
+
+// checkKind reports field types the Decoder can't decode.
+func checkKind(pass *analysis.Pass, f *structField, tag *binio.Tag) {
+	if f.Type == nil || f.Name == "_" || isBinioType(f.Type, "Span") || tag.IsOffset() {
+		return
+	}
+	if unmarshaler(f.Type) {
+		return
+	}
+
+	switch u := f.Type.Underlying().(type) {
+	case *types.Basic:
+		if u.Kind() == types.String {
+			if tag == nil || tag.Size == nil {
+				pass.Reportf(f.pos(), "string field %s needs a size", f.Name)
+			}
+			return
+		}
+	case *types.Slice:
+		if tag == nil || tag.Size == nil && tag.Until == nil && !tag.IsHoleyArray() {
+			pass.Reportf(f.pos(), "slice field %s needs a size", f.Name)
+			return
+		}
+	}
+
+	if why := unsupported(elemType(f.Type)); why != "" {
+		pass.Reportf(f.pos(), "field %s: %s", f.Name, why)
+	}
+}
+
+// elemType strips pointers, slices and arrays from typ.
+func elemType(typ types.Type) types.Type {
+	for {
+		if unmarshaler(typ) {
+			return typ
+		}
+		switch u := typ.Underlying().(type) {
+		case *types.Pointer:
+			typ = u.Elem()
+		case *types.Slice:
+			typ = u.Elem()
+		case *types.Array:
+			typ = u.Elem()
+		default:
+			return typ
+		}
+	}
+}
+
+// unsupported returns why typ can't be decoded or "".
+func unsupported(typ types.Type) string {
+	if unmarshaler(typ) || isBinioType(typ, "Lazy") {
+		return ""
+	}
+	switch u := typ.Underlying().(type) {
+	case *types.Basic:
+		switch u.Kind() {
+		case types.Int, types.Uint, types.Uintptr:
+			return fmt.Sprintf("%s has no fixed size, use a sized integer type", u)
+		case types.Complex64, types.Complex128, types.UnsafePointer:
+			return fmt.Sprintf("type %s is not supported", u)
+		case types.String:
+			return "strings in slices and arrays are not supported"
+		}
+	case *types.Map, *types.Chan, *types.Signature, *types.Interface:
+		return fmt.Sprintf("type %s is not supported", typ)
+	}
+	return ""
+}
+
+func unmarshaler(typ types.Type) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(typ), true, nil, "UnmarshalDAT")
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+func isBinioType(typ types.Type, name string) bool {
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == binioPath && obj.Name() == name
+}
+
+func embeddedName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.StarExpr:
+		return embeddedName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.Ident:
+		return e.Name
+	case *ast.IndexExpr:
+		return embeddedName(e.X)
+	}
+	return ""
+}
+
+func sortedVars(tag *binio.Tag) []string {
+	names := tag.VarNames()
+	sort.Strings(names)
+	return names
+}
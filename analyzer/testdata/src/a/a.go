@@ -0,0 +1,48 @@
+package a
+
+type Entry struct {
+	ID   uint32
+	Name string `bin:"type=dynstring,size=uint8"`
+	Ext  []byte `bin:"size=%_parent.Flags"`
+}
+
+type File struct {
+	Count   uint16
+	Entries []Entry `bin:"size=%Count"`
+	Flags   uint8
+	Ext     uint32 `bin:"if=%Flags"`
+	Last    []byte `bin:"size=%Entries[0].ID"`
+	Version uint8  `bin:"valid=%Version > 0 && %Version <= %Flags"`
+}
+
+type Errors struct {
+	A     uint8
+	B     []byte `bin:"size=%C"` // want `bin tag of B: size refers to field C which is decoded later`
+	C     uint8
+	D     []byte           `bin:"size=%Missing"` // want `bin tag of D: size refers to unknown field Missing`
+	E     uint8            `bin:"iff=1"`         // want `invalid bin tag of E: column 1: invalid tag option: "iff"`
+	F     uint8            `bin:"if=%A &&"`      // want `invalid bin tag of F: column 9: failed to parse condition: unexpected end of expression, expected operand`
+	G     string           // want `string field G needs a size`
+	H     []int16          // want `slice field H needs a size`
+	I     int              // want `field I: int has no fixed size, use a sized integer type`
+	J     map[string]uint8 // want `field J: type map\[string\]uint8 is not supported`
+	K     [4]uint8
+	Tail  []uint8 `bin:"until=$_ == 0"`
+	Holes []uint8 `bin:"type=holeyarray,ptrs=%K"`
+	Check uint8   `bin:"valid=$_ != %Next"` // want `bin tag of Check: valid refers to field Next which is decoded later`
+	Next  uint8
+}
+
+// structs without bin tags are not checked
+type Other struct {
+	M map[string]int
+}
+
+type Custom struct{}
+
+func (*Custom) UnmarshalDAT(dec any) error { return nil }
+
+type WithCustom struct {
+	A uint8 `bin:"if=true"`
+	C Custom
+}
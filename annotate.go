@@ -0,0 +1,207 @@
+package binio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	ansiReset      = "\x1b[0m"
+	ansiPadding    = "\x1b[90m"   // gray
+	ansiUnconsumed = "\x1b[1;41m" // bold on red
+)
+
+// colors of the fields in the hexdump
+var ansiColors = []string{
+	"\x1b[31m", "\x1b[32m", "\x1b[33m", "\x1b[34m", "\x1b[35m", "\x1b[36m",
+}
+
+// Annotation is the result of Annotate.
+type Annotation struct {
+	Data   []byte
+	Value  any         // the decoded value
+	Fields []FieldInfo // all decoded fields, children before their parent
+
+	leaves []FieldInfo // fields without children, by offset
+}
+
+// Annotate reads all data from r, decodes it into v and records the span
+// of every field. If decoding fails, the annotation holds the fields that
+// were decoded up to the error.
+//
+//	a, err := binio.Annotate(f, &header)
+//	a.WriteHexdump(os.Stdout, true)
+func Annotate(r io.Reader, v any) (*Annotation, error) {
+	return annotate(r, func(dec *Decoder) (any, error) {
+		return v, dec.Decode(v)
+	})
+}
+
+// AnnotateSchema is like Annotate for the root type of the schema s.
+func AnnotateSchema(r io.Reader, s *Schema) (*Annotation, error) {
+	return annotate(r, func(dec *Decoder) (any, error) {
+		return dec.DecodeSchema(s)
+	})
+}
+
+func annotate(r io.Reader, decode func(dec *Decoder) (any, error)) (*Annotation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	a := &Annotation{Data: data}
+
+	dec := NewBytesDecoder(data)
+	dec.OnField(func(info FieldInfo) {
+		info.Path = slices.Clone(info.Path)
+		// a field with children directly follows its last child
+		if n := len(a.Fields); n == 0 || len(a.Fields[n-1].Path) <= len(info.Path) {
+			if info.Span.Length > 0 {
+				a.leaves = append(a.leaves, info)
+			}
+		}
+		a.Fields = append(a.Fields, info)
+	})
+	a.Value, err = decode(dec)
+	sort.SliceStable(a.leaves, func(i, j int) bool {
+		return a.leaves[i].Span.Offset < a.leaves[j].Span.Offset
+	})
+	return a, err
+}
+
+// Span returns the span of the field with the given path.
+func (a *Annotation) Span(path ...string) (Span, bool) {
+	for _, f := range a.Fields {
+		if slices.Equal(f.Path, path) {
+			return f.Span, true
+		}
+	}
+	return Span{}, false
+}
+
+// FieldAt returns the innermost field containing the byte at offset off
+// or nil if the byte was not consumed by any field.
+func (a *Annotation) FieldAt(off int64) *FieldInfo {
+	i := sort.Search(len(a.leaves), func(i int) bool {
+		return a.leaves[i].Span.End() > off
+	})
+	if i < len(a.leaves) && a.leaves[i].Span.Contains(off) {
+		return &a.leaves[i]
+	}
+	return nil
+}
+
+// Unconsumed returns the ranges of Data which were not read by any field.
+func (a *Annotation) Unconsumed() []Span {
+	var (
+		res []Span
+		pos int64
+	)
+	for _, f := range a.leaves {
+		if f.Span.Offset > pos {
+			res = append(res, Span{Offset: pos, Length: f.Span.Offset - pos})
+		}
+		pos = max(pos, f.Span.End())
+	}
+	if end := int64(len(a.Data)); end > pos {
+		res = append(res, Span{Offset: pos, Length: end - pos})
+	}
+	return res
+}
+
+// WriteHexdump writes a hexdump of Data to w with the names of the fields
+// starting on each line next to it. Skipped fields are named "_" and
+// bytes never consumed are marked "(not decoded)". If color is set, the
+// bytes of each field are highlighted with ANSI escape sequences.
+func (a *Annotation) WriteHexdump(w io.Writer, color bool) error {
+	const width = 16
+
+	// owner[i] is the index of the leaf containing byte i, or -1
+	owner := make([]int, len(a.Data))
+	for i := range owner {
+		owner[i] = -1
+	}
+	for k, f := range a.leaves {
+		for i := f.Span.Offset; i < f.Span.End() && i < int64(len(owner)); i++ {
+			owner[i] = k
+		}
+	}
+
+	style := func(i int) string {
+		switch k := owner[i]; {
+		case k < 0:
+			return ansiUnconsumed
+		case a.leaves[k].Skipped:
+			return ansiPadding
+		default:
+			return ansiColors[k%len(ansiColors)]
+		}
+	}
+
+	paint := func(s string, i int) string {
+		if !color {
+			return s
+		}
+		return style(i) + s + ansiReset
+	}
+
+	bw := bufio.NewWriter(w)
+	for line := 0; line < len(a.Data); line += width {
+		end := min(line+width, len(a.Data))
+
+		var hex, ascii strings.Builder
+		for i := line; i < line+width; i++ {
+			if i == line+width/2 {
+				hex.WriteByte(' ')
+			}
+			if i >= end {
+				hex.WriteString("   ")
+				continue
+			}
+			hex.WriteString(paint(fmt.Sprintf("%02x", a.Data[i]), i))
+			hex.WriteByte(' ')
+
+			c := a.Data[i]
+			if c < 0x20 || c >= 0x7f {
+				c = '.'
+			}
+			ascii.WriteString(paint(string(c), i))
+		}
+
+		// names of the fields and gaps starting on this line
+		var names []string
+		for i := line; i < end; i++ {
+			// fields continued from the previous line are not repeated,
+			// gaps are
+			k := owner[i]
+			if i > 0 && owner[i-1] == k && (k >= 0 || i > line) {
+				continue
+			}
+			name := "(not decoded)"
+			if k >= 0 {
+				name = JoinPath(a.leaves[k].Path)
+			}
+			names = append(names, paint(name, i))
+		}
+		fmt.Fprintf(bw, "%08x  %s |%s|", line, hex.String(), ascii.String())
+		if pad := width - (end - line); pad > 0 {
+			bw.WriteString(strings.Repeat(" ", pad))
+		}
+		if len(names) > 0 {
+			bw.WriteString("  " + strings.Join(names, ", "))
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// String returns the hexdump without colors.
+func (a *Annotation) String() string {
+	var sb strings.Builder
+	a.WriteHexdump(&sb, false)
+	return sb.String()
+}
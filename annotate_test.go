@@ -0,0 +1,60 @@
+package binio_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotate(t *testing.T) {
+	type Struct struct {
+		Magic [4]byte
+		_     [2]byte
+		Len   uint16
+		Name  string `bin:"size=%Len"`
+	}
+
+	data := append(pack([4]byte{'B', 'I', 'N', '!'}, [2]byte{}, uint16(12), []byte("hello world!")), 0xaa, 0xbb)
+
+	var res Struct
+	a, err := binio.Annotate(bytes.NewReader(data), &res)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "hello world!", res.Name)
+	assert.Len(t, a.Fields, 4)
+
+	if f := a.FieldAt(9); assert.NotNil(t, f) {
+		assert.Equal(t, []string{"Name"}, f.Path)
+		assert.Equal(t, binio.Span{Offset: 8, Length: 12}, f.Span)
+	}
+	assert.Nil(t, a.FieldAt(20))
+	assert.Equal(t, []binio.Span{{Offset: 20, Length: 2}}, a.Unconsumed())
+
+	assert.Equal(t, ""+
+		"00000000  42 49 4e 21 00 00 0c 00  68 65 6c 6c 6f 20 77 6f  |BIN!....hello wo|  Magic, _, Len, Name\n"+
+		"00000010  72 6c 64 21 aa bb                                 |rld!..|            (not decoded)\n",
+		a.String())
+
+	var buf bytes.Buffer
+	if assert.NoError(t, a.WriteHexdump(&buf, true)) {
+		assert.True(t, strings.Contains(buf.String(), "\x1b[1;41maa\x1b[0m"), "unconsumed bytes are highlighted")
+	}
+}
+
+func TestAnnotate_error(t *testing.T) {
+	type Struct struct {
+		A uint16
+		B uint32
+	}
+
+	var res Struct
+	a, err := binio.Annotate(bytes.NewReader([]byte{1, 0, 2}), &res)
+	assert.Error(t, err)
+	if assert.NotNil(t, a) && assert.Len(t, a.Fields, 1) {
+		assert.Equal(t, []string{"A"}, a.Fields[0].Path)
+	}
+}
@@ -2,7 +2,6 @@ package binio
 
 import (
 	"errors"
-	"fmt"
 	ref "reflect"
 )
 
@@ -17,6 +16,10 @@ var (
 	// e.g. for a field with the type string we need one
 	ErrMissingTag  = errors.New("missing field tag")
 	ErrMissingSize = errors.New("missing size")
+
+	// ErrInvalidValue will be returned if the valid expression of a field
+	// is false for the decoded value
+	ErrInvalidValue = errors.New("invalid value")
 )
 
 func RegisterDecoder(typ ref.Type, dec DecodeFunc) {
@@ -52,22 +55,6 @@ var (
 	}
 )
 
-func ValueSize(v ref.Type) (int, error) {
-	if n, found := sizes[v.Kind()]; found {
-		return n, nil
-	}
-	switch v.Kind() {
-	case ref.Array:
-		n, err := ValueSize(v.Elem())
-		if err != nil {
-			return 0, fmt.Errorf("unable to get size of array element: %w", err)
-		}
-		return n * v.Len(), nil
-	default:
-		return 0, fmt.Errorf("sizeof() unhandled type %s", v.Kind())
-	}
-}
-
 func IntSize(name string) int {
 	if kind, found := intNames[name]; found {
 		return sizes[kind]
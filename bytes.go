@@ -0,0 +1,97 @@
+package binio
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// NewBytesDecoder returns a decoder that reads directly from buf.
+//
+// Unlike a decoder created by NewDecoder, []byte fields decoded by it
+// alias buf instead of being copied, so buf must not be modified while
+// the decoded values are in use. See AliasStrings to do the same for
+// strings.
+func NewBytesDecoder(buf []byte) *Decoder {
+	if buf == nil {
+		buf = []byte{}
+	}
+	return &Decoder{
+		buf:   buf,
+		pos:   0,
+		stack: make([]state, 0, 100),
+	}
+}
+
+// UnmarshalBytes decodes buf into v without copying []byte fields.
+func UnmarshalBytes(buf []byte, v any) error {
+	dec := NewBytesDecoder(buf)
+	return dec.Decode(v)
+}
+
+// AliasStrings makes the decoder return strings that share their memory
+// with the input buffer. It has no effect on decoders not created by
+// NewBytesDecoder.
+func (dec *Decoder) AliasStrings() {
+	dec.aliasStrings = true
+}
+
+// Remaining returns the number of bytes in the input buffer that have not
+// been consumed yet, or -1 if the decoder is not backed by a byte slice.
+func (dec *Decoder) Remaining() int {
+	if dec.buf == nil {
+		return -1
+	}
+	return len(dec.buf) - int(dec.pos)
+}
+
+// next consumes n bytes of the input buffer and returns them without
+// copying.
+func (dec *Decoder) next(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid size %d", n)
+	}
+	rest := dec.buf[dec.pos:]
+	if len(rest) < n {
+		dec.pos = int64(len(dec.buf))
+		if len(rest) == 0 {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	dec.pos += int64(n)
+	return rest[:n:n], nil
+}
+
+func isByteSlice(typ reflect.Type) bool {
+	elem := typ.Elem()
+	if elem.Kind() != reflect.Uint8 {
+		return false
+	}
+	if _, found := decoderFuncs[elem]; found {
+		return false
+	}
+	return !reflect.PointerTo(elem).Implements(unmarshalerType)
+}
+
+// readBytes consumes n bytes. For decoders created by NewBytesDecoder the
+// result aliases the input buffer.
+func (dec *Decoder) readBytes(n int) ([]byte, error) {
+	if dec.buf != nil {
+		return dec.next(n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(dec, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (dec *Decoder) byteSlice(v reflect.Value, size int) error {
+	buf, err := dec.readBytes(size)
+	if err != nil {
+		return err
+	}
+	v.SetBytes(buf)
+	return nil
+}
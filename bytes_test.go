@@ -0,0 +1,81 @@
+package binio_test
+
+import (
+	"io"
+	"testing"
+	"unsafe"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalBytes(t *testing.T) {
+	type Struct struct {
+		Len  uint16
+		Data []byte `bin:"size=%Len"`
+		Name string `bin:"size=4"`
+		End  uint32
+	}
+
+	buf := pack(uint16(3), []byte{1, 2, 3}, []byte("ab\x00\x00"), uint32(42))
+
+	var res Struct
+	err := binio.UnmarshalBytes(buf, &res)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte{1, 2, 3}, res.Data)
+		assert.Equal(t, "ab", res.Name)
+		assert.Equal(t, uint32(42), res.End)
+
+		// Data must alias the input
+		assert.Same(t, &buf[2], &res.Data[0])
+		assert.Equal(t, 3, cap(res.Data))
+	}
+}
+
+func TestBytesDecoder_aliasStrings(t *testing.T) {
+	type Struct struct {
+		Name string `bin:"size=5"`
+	}
+
+	buf := []byte("hello")
+
+	var res Struct
+	dec := binio.NewBytesDecoder(buf)
+	dec.AliasStrings()
+	if assert.NoError(t, dec.Decode(&res)) {
+		assert.Equal(t, "hello", res.Name)
+		assert.Equal(t, unsafe.Pointer(&buf[0]), unsafe.Pointer(unsafe.StringData(res.Name)))
+	}
+}
+
+func TestBytesDecoder_remaining(t *testing.T) {
+	buf := pack(uint32(1), uint16(2), uint8(3))
+
+	dec := binio.NewBytesDecoder(buf)
+	assert.Equal(t, 7, dec.Remaining())
+
+	var v uint32
+	if assert.NoError(t, dec.Decode(&v)) {
+		assert.Equal(t, 3, dec.Remaining())
+	}
+	assert.NoError(t, dec.Skip(2))
+	assert.Equal(t, 1, dec.Remaining())
+	assert.Equal(t, int64(6), dec.Pos())
+
+	assert.Equal(t, uint8(3), dec.Uint8())
+	assert.Equal(t, 0, dec.Remaining())
+
+	assert.ErrorIs(t, dec.Skip(1), io.EOF)
+
+	assert.Equal(t, -1, binio.NewDecoder(NullReader).Remaining())
+}
+
+func TestBytesDecoder_truncated(t *testing.T) {
+	type Struct struct {
+		Data []byte `bin:"size=8"`
+	}
+
+	var res Struct
+	err := binio.UnmarshalBytes([]byte{1, 2, 3}, &res)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
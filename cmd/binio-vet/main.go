@@ -0,0 +1,19 @@
+// Command binio-vet checks the bin struct tags of binio.
+//
+// Usage:
+//
+//	binio-vet ./...
+//
+// or as a vet tool:
+//
+//	go vet -vettool=$(which binio-vet) ./...
+package main
+
+import (
+	"github.com/KlemensWinter/go-binio/analyzer"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
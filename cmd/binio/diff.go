@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/KlemensWinter/go-binio"
+)
+
+var errDiffer = errors.New("files differ")
+
+func runDiff(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	var (
+		schemaFile = fs.String("schema", "", "schema file (.yaml, .json or .ksy)")
+		formatName = fs.String("format", "", "compiled-in format, see binio formats")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: binio diff [-schema file | -format name] file1 file2\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("need exactly two files")
+	}
+
+	src, err := loadSource(*schemaFile, *formatName)
+	if err != nil {
+		return err
+	}
+	var annotations [2]*binio.Annotation
+	for i, name := range fs.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if annotations[i], err = src.annotate(data); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	diffs, err := binio.Diff(annotations[0], annotations[1])
+	if err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		fmt.Fprintln(stdout, d)
+	}
+	if len(diffs) > 0 {
+		return errDiffer
+	}
+	return nil
+}
+
+// annotate decodes data and records the spans of all fields.
+func (src *source) annotate(data []byte) (*binio.Annotation, error) {
+	if src.schema != nil {
+		return binio.AnnotateSchema(bytes.NewReader(data), src.schema)
+	}
+	return binio.Annotate(bytes.NewReader(data), src.format.New())
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/KlemensWinter/go-binio/ksy"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// node is a decoded field with its position in the file
+	node struct {
+		Name     string
+		Span     binio.Span
+		Value    any
+		Skipped  bool
+		Children []*node
+
+		depth int
+	}
+
+	// source decodes a file either with a schema or a compiled-in format
+	source struct {
+		Name   string
+		schema *binio.Schema
+		format *format
+	}
+)
+
+func runDump(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	var (
+		schemaFile = fs.String("schema", "", "schema file (.yaml, .json or .ksy)")
+		formatName = fs.String("format", "", "compiled-in format, see binio formats")
+		output     = fs.String("o", "tree", "output format: tree, json or yaml")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: binio dump [-schema file | -format name] [-o tree|json|yaml] file\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("need exactly one file")
+	}
+
+	src, err := loadSource(*schemaFile, *formatName)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	root, err := src.decode(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	switch *output {
+	case "tree":
+		return writeTree(stdout, root, int64(len(data)))
+	case "json":
+		return writeJSON(stdout, root)
+	case "yaml":
+		return writeYAML(stdout, root)
+	default:
+		return fmt.Errorf("invalid output format %q", *output)
+	}
+}
+
+func loadSource(schemaFile, formatName string) (*source, error) {
+	switch {
+	case schemaFile != "" && formatName != "":
+		return nil, errors.New("-schema and -format are mutually exclusive")
+	case formatName != "":
+		f, found := formats[formatName]
+		if !found {
+			return nil, fmt.Errorf("unknown format %q", formatName)
+		}
+		return &source{Name: f.Name, format: f}, nil
+	case schemaFile != "":
+		data, err := os.ReadFile(schemaFile)
+		if err != nil {
+			return nil, err
+		}
+		var s *binio.Schema
+		if strings.EqualFold(filepath.Ext(schemaFile), ".ksy") {
+			spec, err := ksy.Parse(data)
+			if err != nil {
+				return nil, err
+			}
+			s, err = spec.Schema()
+		} else {
+			s, err = binio.ParseSchema(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", schemaFile, err)
+		}
+		return &source{Name: s.Root, schema: s}, nil
+	default:
+		return nil, errors.New("need -schema or -format")
+	}
+}
+
+// decode decodes data and returns the tree of decoded fields.
+func (src *source) decode(data []byte) (*node, error) {
+	dec := binio.NewBytesDecoder(data)
+
+	// fields are reported after their children, so the children of a
+	// field are the last pending nodes with a greater depth
+	var pending []*node
+	dec.OnField(func(info binio.FieldInfo) {
+		n := &node{
+			Name:    info.Path[len(info.Path)-1],
+			Span:    info.Span,
+			Value:   info.Value,
+			Skipped: info.Skipped,
+			depth:   len(info.Path),
+		}
+		i := len(pending)
+		for i > 0 && pending[i-1].depth > n.depth {
+			i--
+		}
+		n.Children = slices.Clone(pending[i:])
+		pending = append(pending[:i], n)
+	})
+
+	var (
+		v   any
+		err error
+	)
+	if src.schema != nil {
+		v, err = dec.DecodeSchema(src.schema)
+	} else {
+		v = src.format.New()
+		err = dec.Decode(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &node{
+		Name:     src.Name,
+		Span:     binio.Span{Offset: 0, Length: dec.Pos()},
+		Value:    v,
+		Children: pending,
+	}, nil
+}
+
+// isList reports whether the children of n are elements.
+func (n *node) isList() bool {
+	return len(n.Children) > 0 && strings.HasPrefix(n.Children[0].Name, "[")
+}
+
+// items returns the children of n for the JSON and YAML output. Elements
+// missing from holey arrays are filled in with their value.
+func (n *node) items() []*node {
+	if !n.isList() {
+		return n.Children
+	}
+	rv := reflect.ValueOf(n.Value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return n.Children
+	}
+	items := make([]*node, rv.Len())
+	for _, c := range n.Children {
+		var i int
+		if _, err := fmt.Sscanf(c.Name, "[%d]", &i); err == nil && i < len(items) {
+			items[i] = c
+		}
+	}
+	for i := range items {
+		if items[i] == nil {
+			items[i] = &node{Name: fmt.Sprintf("[%d]", i), Value: rv.Index(i).Interface()}
+		}
+	}
+	return items
+}
+
+func writeTree(w io.Writer, root *node, size int64) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%-8s %8s  %s\n", "OFFSET", "SIZE", "FIELD")
+	var walk func(n *node, indent string)
+	walk = func(n *node, indent string) {
+		fmt.Fprintf(&buf, "%08x %8d  %s%s", n.Span.Offset, n.Span.Length, indent, n.Name)
+		switch {
+		case n.Skipped:
+			buf.WriteString(" (skipped)")
+		case len(n.Children) == 0:
+			buf.WriteString(" = " + formatValue(n.Value))
+		}
+		buf.WriteByte('\n')
+		for _, c := range n.Children {
+			walk(c, indent+"  ")
+		}
+	}
+	walk(root, "")
+	if rest := size - root.Span.End(); rest > 0 {
+		fmt.Fprintf(&buf, "%08x %8d  (not decoded)\n", root.Span.End(), rest)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// formatValue formats a leaf value for the tree output.
+func formatValue(v any) string {
+	if b, ok := byteValue(v); ok {
+		const max = 16
+		s := hex.EncodeToString(b[:min(len(b), max)])
+		if len(b) > max {
+			s += fmt.Sprintf("... (%d bytes)", len(b))
+		}
+		if len(b) > 0 && len(b) <= max && printable(b) {
+			s += fmt.Sprintf(" %q", b)
+		}
+		return s
+	}
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
+
+// byteValue returns the content of byte slices and arrays.
+func byteValue(v any) ([]byte, bool) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return rv.Bytes(), true
+	case rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8:
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return b, true
+	}
+	return nil, false
+}
+
+func printable(b []byte) bool {
+	for _, c := range b {
+		if c >= unicode.MaxASCII || !unicode.IsPrint(rune(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonValue converts byte slices and arrays into hex strings.
+func jsonValue(v any) any {
+	if b, ok := byteValue(v); ok {
+		return hex.EncodeToString(b)
+	}
+	return v
+}
+
+func writeJSON(w io.Writer, root *node) error {
+	var buf bytes.Buffer
+	var walk func(n *node, indent string) error
+	walk = func(n *node, indent string) error {
+		if len(n.Children) == 0 {
+			data, err := json.Marshal(jsonValue(n.Value))
+			if err != nil {
+				return fmt.Errorf("%s: %w", n.Name, err)
+			}
+			buf.Write(data)
+			return nil
+		}
+		open, close := "{", "}"
+		if n.isList() {
+			open, close = "[", "]"
+		}
+		buf.WriteString(open)
+		first := true
+		for _, c := range n.items() {
+			if c.Skipped {
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			buf.WriteString("\n" + indent + "  ")
+			if !n.isList() {
+				key, _ := json.Marshal(c.Name)
+				buf.Write(key)
+				buf.WriteString(": ")
+			}
+			if err := walk(c, indent+"  "); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + close)
+		return nil
+	}
+	if err := walk(root, ""); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func yamlNode(n *node) (*yaml.Node, error) {
+	if len(n.Children) == 0 {
+		y := &yaml.Node{}
+		if err := y.Encode(jsonValue(n.Value)); err != nil {
+			return nil, fmt.Errorf("%s: %w", n.Name, err)
+		}
+		return y, nil
+	}
+	y := &yaml.Node{Kind: yaml.MappingNode}
+	if n.isList() {
+		y.Kind = yaml.SequenceNode
+	}
+	for _, c := range n.items() {
+		if c.Skipped {
+			continue
+		}
+		cy, err := yamlNode(c)
+		if err != nil {
+			return nil, err
+		}
+		if y.Kind == yaml.MappingNode {
+			y.Content = append(y.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: c.Name})
+		}
+		y.Content = append(y.Content, cy)
+	}
+	return y, nil
+}
+
+func writeYAML(w io.Writer, root *node) error {
+	y, err := yamlNode(root)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(y); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSource(t *testing.T) *source {
+	s, err := binio.ParseSchema([]byte(`
+root: File
+types:
+  File:
+    - {name: Magic, type: "[2]uint8"}
+    - {name: Count, type: uint8}
+    - {name: Points, type: "[]Point", tag: "size=%Count"}
+  Point:
+    - {name: X, type: int16}
+    - {name: Y, type: int16}
+`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return &source{Name: s.Root, schema: s}
+}
+
+func TestDump(t *testing.T) {
+	data := []byte{'P', 'T', 1, 1, 0, 2, 0, 0xff}
+
+	root, err := testSource(t).decode(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if assert.NoError(t, writeTree(&buf, root, int64(len(data)))) {
+		assert.Equal(t, `OFFSET       SIZE  FIELD
+00000000        7  File
+00000000        2    Magic = 5054 "PT"
+00000002        1    Count = 1
+00000003        4    Points
+00000003        4      [0]
+00000003        2        X = 1
+00000005        2        Y = 2
+00000007        1  (not decoded)
+`, buf.String())
+	}
+
+	buf.Reset()
+	if assert.NoError(t, writeJSON(&buf, root)) {
+		assert.Equal(t, `{
+  "Magic": "5054",
+  "Count": 1,
+  "Points": [
+    {
+      "X": 1,
+      "Y": 2
+    }
+  ]
+}
+`, buf.String())
+	}
+}
+
+func TestDump_error(t *testing.T) {
+	_, err := testSource(t).decode([]byte{'P', 'T', 2, 1, 0, 2, 0, 3})
+	assert.EqualError(t, err, "decoding error at Points.X (8): unexpected EOF")
+}
+
+func TestDiff(t *testing.T) {
+	src := testSource(t)
+	a, err := src.annotate([]byte{'P', 'T', 1, 1, 0, 2, 0})
+	if !assert.NoError(t, err) {
+		return
+	}
+	b, err := src.annotate([]byte{'P', 'T', 1, 1, 0, 3, 0})
+	if !assert.NoError(t, err) {
+		return
+	}
+	diffs, err := binio.Diff(a, b)
+	if assert.NoError(t, err) && assert.Len(t, diffs, 1) {
+		assert.Equal(t, "Points[0].Y: 2 -> 3 (a: 0x5+2, b: 0x5+2)", diffs[0].String())
+	}
+}
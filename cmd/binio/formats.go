@@ -0,0 +1,49 @@
+package main
+
+type format struct {
+	Name string
+	Doc  string
+	New  func() any // returns a pointer to a new value
+}
+
+// formats are the compiled-in formats, selected with -format
+var formats = make(map[string]*format)
+
+func register(name, doc string, new func() any) {
+	if _, found := formats[name]; found {
+		panic("format " + name + " registered twice")
+	}
+	formats[name] = &format{Name: name, Doc: doc, New: new}
+}
+
+func init() {
+	register("bmp", "Windows bitmap file and info header", func() any { return new(bmpFile) })
+}
+
+type (
+	bmpFile struct {
+		Header bmpFileHeader
+		Info   bmpInfoHeader
+	}
+
+	bmpFileHeader struct {
+		Magic      [2]byte
+		FileSize   uint32
+		_          [4]byte
+		DataOffset uint32
+	}
+
+	bmpInfoHeader struct {
+		HeaderSize      uint32
+		Width           int32
+		Height          int32
+		Planes          uint16
+		BitCount        uint16
+		Compression     uint32
+		ImageSize       uint32
+		XPelsPerMeter   int32
+		YPelsPerMeter   int32
+		ColorsUsed      uint32
+		ColorsImportant uint32
+	}
+)
@@ -0,0 +1,75 @@
+// Command binio decodes binary files with a runtime schema or one of the
+// compiled-in formats.
+//
+// Usage:
+//
+//	binio dump [-schema file | -format name] [-o tree|json|yaml] file
+//	binio diff [-schema file | -format name] file1 file2
+//	binio formats
+//
+// Schema files are binio schemas in YAML or JSON, or Kaitai Struct
+// descriptions (.ksy).
+//
+// diff prints the fields which differ between the two files with their
+// offsets and exits with status 1 if there are differences.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+type command struct {
+	Name  string
+	Usage string
+	Run   func(args []string, stdout io.Writer) error
+}
+
+var commands = []*command{
+	{"dump", "decode a file and print the result", runDump},
+	{"diff", "compare two files field by field", runDiff},
+	{"formats", "list the compiled-in formats", runFormats},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: binio <command> [arguments]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.Name, cmd.Usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	for _, cmd := range commands {
+		if cmd.Name != os.Args[1] {
+			continue
+		}
+		err := cmd.Run(os.Args[2:], os.Stdout)
+		if err == errDiffer {
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "binio %s: %v\n", cmd.Name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	usage()
+}
+
+func runFormats(args []string, stdout io.Writer) error {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(stdout, "%-10s %s\n", name, formats[name].Doc)
+	}
+	return nil
+}
@@ -77,3 +77,63 @@ func TestConditionError(t *testing.T) {
 	err := binio.Unmarshal(rd, &v)
 	assert.Error(t, err)
 }
+
+func TestDecodeCondition_fourCC(t *testing.T) {
+	type Chunk struct {
+		ID   [4]byte
+		Size uint8
+		Fmt  *uint16 `bin:"if=%ID == 'fmt '"`
+		Data []byte  `bin:"size=%Size,if=startswith(%ID, \"da\")"`
+	}
+	type File struct {
+		Chunks []Chunk `bin:"size=2"`
+	}
+
+	var res File
+	err := binio.UnmarshalBytes(pack([4]byte{'f', 'm', 't', ' '}, uint8(2), uint16(1), [4]byte{'d', 'a', 't', 'a'}, uint8(3), []byte{1, 2, 3}), &res)
+	if assert.NoError(t, err) {
+		if assert.NotNil(t, res.Chunks[0].Fmt) {
+			assert.Equal(t, uint16(1), *res.Chunks[0].Fmt)
+		}
+		assert.Nil(t, res.Chunks[0].Data)
+		assert.Nil(t, res.Chunks[1].Fmt)
+		assert.Equal(t, []byte{1, 2, 3}, res.Chunks[1].Data)
+	}
+}
+
+func TestDecodeCondition_shortCircuit(t *testing.T) {
+	type Ext struct {
+		Count uint8
+	}
+	type Struct struct {
+		HasExt bool
+		Ext    *Ext    `bin:"if=%HasExt"`
+		Data   []uint8 `bin:"size=%Ext.Count * 2,if=%HasExt && %Ext.Count > 0"`
+		Big    uint64
+		IsBig  *uint8 `bin:"if=%Big > 9223372036854775807"`
+	}
+
+	var res Struct
+	err := binio.UnmarshalBytes(pack(false, uint64(math.MaxUint64), uint8(1)), &res)
+	if assert.NoError(t, err) {
+		assert.Nil(t, res.Ext)
+		assert.Nil(t, res.Data)
+		assert.NotNil(t, res.IsBig)
+	}
+
+	res = Struct{}
+	err = binio.UnmarshalBytes(pack(true, uint8(2), []uint8{1, 2, 3, 4}, uint64(1)), &res)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []uint8{1, 2, 3, 4}, res.Data)
+		assert.Nil(t, res.IsBig)
+	}
+}
+
+func TestDecodeCondition_negativeSize(t *testing.T) {
+	var res struct {
+		N    int8
+		Data []byte `bin:"size=%N"`
+	}
+	err := binio.UnmarshalBytes(pack(int8(-1)), &res)
+	assert.ErrorContains(t, err, "negative size -1")
+}
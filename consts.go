@@ -0,0 +1,142 @@
+package binio
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+
+	"github.com/KlemensWinter/go-binio/expr"
+	"golang.org/x/exp/constraints"
+)
+
+var (
+	// consts are the named constants of expressions, see RegisterConst
+	consts map[string]any
+
+	// types are the named types of expressions, see RegisterType
+	types = map[string]reflect.Type{
+		"bool":    reflect.TypeOf(false),
+		"float32": reflect.TypeOf(float32(0)),
+		"float64": reflect.TypeOf(float64(0)),
+	}
+
+	// funcs are the functions of expressions in addition to the builtins
+	// of package expr:
+	//
+	//	sizeof(T)  the encoded size of the fixed size type T, see ValueSize
+	funcs map[string]expr.Func
+)
+
+func init() {
+	// set in init: sizeof refers to the layouts, which refer to funcs
+	funcs = map[string]expr.Func{
+		"sizeof": sizeOf(nil),
+	}
+}
+
+// RegisterConst makes the constant name available in tag expressions, like
+// CHUNK_DATA in if=%Type == CHUNK_DATA. The value must be an integer, a
+// float, a bool or a string. RegisterConst panics if name is not an
+// identifier, is an integer type name or is already registered with a
+// different value.
+func RegisterConst(name string, value any) {
+	if !token.IsIdentifier(name) || IntSize(name) != -1 {
+		panic(fmt.Errorf("binio: invalid constant name %q", name))
+	}
+	if _, found := types[name]; found {
+		panic(fmt.Errorf("binio: constant %s is already registered as type", name))
+	}
+	v := expr.Value(reflect.ValueOf(value))
+	switch v.(type) {
+	case int64, uint64, float64, bool, string:
+	default:
+		panic(fmt.Errorf("binio: invalid type %T of constant %s", value, name))
+	}
+	if old, found := consts[name]; found && old != v {
+		panic(fmt.Errorf("binio: constant %s already registered with value %v", name, old))
+	}
+	if consts == nil {
+		consts = make(map[string]any)
+	}
+	consts[name] = v
+}
+
+// RegisterEnum registers the values of an enum type as constants named by
+// their String method, usually generated with stringer:
+//
+//	type ChunkType uint8
+//
+//	const (
+//		CHUNK_HEADER ChunkType = iota
+//		CHUNK_DATA
+//	)
+//
+//	binio.RegisterEnum(CHUNK_HEADER, CHUNK_DATA)
+func RegisterEnum[T interface {
+	constraints.Integer
+	fmt.Stringer
+}](values ...T) {
+	for _, v := range values {
+		RegisterConst(v.String(), v)
+	}
+}
+
+// lookupConst returns the value of the constant name.
+func lookupConst(name string) (any, bool) {
+	v, found := consts[name]
+	return v, found
+}
+
+// RegisterType makes the type T available as name in tag expressions,
+// like Header in size=sizeof(Header). RegisterType panics if name is not
+// an identifier, is an integer type name or a constant, or is already
+// registered with a different type. The names of the integer types, bool,
+// float32 and float64 are predefined.
+func RegisterType[T any](name string) {
+	if !token.IsIdentifier(name) || IntSize(name) != -1 {
+		panic(fmt.Errorf("binio: invalid type name %q", name))
+	}
+	if _, found := consts[name]; found {
+		panic(fmt.Errorf("binio: type %s is already registered as constant", name))
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if old, found := types[name]; found && old != typ {
+		panic(fmt.Errorf("binio: type %s already registered as %s", name, old))
+	}
+	types[name] = typ
+}
+
+// lookupType returns the type name.
+func lookupType(name string) (reflect.Type, bool) {
+	t, found := types[name]
+	return t, found
+}
+
+// lookupFunc returns the function name, see funcs.
+func lookupFunc(name string) (expr.Func, bool) {
+	fn, found := funcs[name]
+	return fn, found
+}
+
+// sizeOf returns the sizeof function, which returns the encoded size of
+// a type. The names of the integer types evaluate to their sizes, so
+// sizeof(uint16) gets the size itself. The sizes of the structs in
+// visiting are recursive, see describe.
+func sizeOf(visiting []reflect.Type) expr.Func {
+	return func(args ...any) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("want 1 argument, got %d", len(args))
+		}
+		switch t := args[0].(type) {
+		case reflect.Type:
+			l := describe(t, nil, t.Name(), 0, visiting)
+			if err := l.sizeErr(); err != nil {
+				return nil, err
+			}
+			return l.Size, nil
+		case int64:
+			return t, nil
+		}
+		return nil, fmt.Errorf("%w: want type, got %v", expr.ErrType, args[0])
+	}
+}
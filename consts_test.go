@@ -0,0 +1,164 @@
+package binio_test
+
+import (
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/KlemensWinter/go-binio/expr"
+	"github.com/stretchr/testify/assert"
+)
+
+type chunkType uint8
+
+const (
+	CHUNK_HEADER chunkType = iota + 1
+	CHUNK_DATA
+)
+
+func (t chunkType) String() string {
+	switch t {
+	case CHUNK_HEADER:
+		return "CHUNK_HEADER"
+	case CHUNK_DATA:
+		return "CHUNK_DATA"
+	}
+	return "chunkType(?)"
+}
+
+func init() {
+	binio.RegisterEnum(CHUNK_HEADER, CHUNK_DATA)
+	binio.RegisterConst("MAX_NAME", 4)
+}
+
+func TestRegisterConst(t *testing.T) {
+	type Chunk struct {
+		Type   chunkType
+		Header *uint16 `bin:"if=%Type == CHUNK_HEADER"`
+		Data   []byte  `bin:"size=MAX_NAME,if=%Type == CHUNK_DATA"`
+	}
+	type File struct {
+		Chunks []Chunk `bin:"size=2"`
+	}
+
+	var have File
+	err := binio.UnmarshalBytes(pack(CHUNK_HEADER, uint16(7), CHUNK_DATA, []byte("abcd")), &have)
+	if assert.NoError(t, err) {
+		if assert.NotNil(t, have.Chunks[0].Header) {
+			assert.Equal(t, uint16(7), *have.Chunks[0].Header)
+		}
+		assert.Nil(t, have.Chunks[0].Data)
+		assert.Nil(t, have.Chunks[1].Header)
+		assert.Equal(t, []byte("abcd"), have.Chunks[1].Data)
+	}
+	assert.NoError(t, binio.Validate[File]())
+
+	// registering the same value again is fine
+	binio.RegisterConst("MAX_NAME", uint8(4))
+}
+
+func TestRegisterConst_invalid(t *testing.T) {
+	assert.Panics(t, func() { binio.RegisterConst("MAX_NAME", 5) })
+	assert.Panics(t, func() { binio.RegisterConst("uint16", 1) })
+	assert.Panics(t, func() { binio.RegisterConst("not a name", 1) })
+	assert.Panics(t, func() { binio.RegisterConst("SLICE", []int{1}) })
+}
+
+func TestRegisterConst_unknown(t *testing.T) {
+	type Struct struct {
+		Type uint8
+		Data []byte `bin:"size=4,if=%Type == CHUNK_MISSING"`
+	}
+
+	err := binio.Validate[Struct]()
+	assert.ErrorIs(t, err, expr.ErrIdentNotFound)
+	assert.EqualError(t, err, "binio_test.Struct.Data: if: unknown identifier: CHUNK_MISSING")
+
+	var v Struct
+	err = binio.UnmarshalBytes([]byte{1, 0, 0, 0, 0}, &v)
+	assert.ErrorIs(t, err, expr.ErrIdentNotFound)
+}
+
+type sizedHeader struct {
+	Magic [4]byte
+	Count uint16
+	_     [2]byte
+}
+
+func init() {
+	binio.RegisterType[sizedHeader]("Header")
+}
+
+func TestRegisterType(t *testing.T) {
+	type File struct {
+		Raw    uint8
+		Header sizedHeader
+		Rest   []byte   `bin:"size=sizeof(Header) - 6"`
+		Words  []uint16 `bin:"size=uint8(%Raw + 1) / sizeof(uint16)"`
+	}
+	data := pack(uint8(255), []byte("RIFF"), uint16(1), []byte{0, 0}, []byte{1, 2})
+	var have File
+	if assert.NoError(t, binio.UnmarshalBytes(data, &have)) {
+		assert.Equal(t, uint16(1), have.Header.Count)
+		assert.Equal(t, []byte{1, 2}, have.Rest)
+		assert.Empty(t, have.Words)
+	}
+	assert.NoError(t, binio.Validate[File]())
+
+	// sizeof of fixed size types is a constant size
+	type Fixed struct {
+		Data []byte `bin:"size=sizeof(Header) * 2"`
+	}
+	n, err := binio.SizeOf[Fixed]()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 16, n)
+	}
+
+	type Variable struct {
+		Data []byte `bin:"size=sizeof(File)"`
+	}
+	binio.RegisterType[File]("File")
+	err = binio.UnmarshalBytes(data, &Variable{})
+	assert.ErrorIs(t, err, binio.ErrVariableSize)
+
+	// registering the same type again is fine
+	binio.RegisterType[sizedHeader]("Header")
+	assert.Panics(t, func() { binio.RegisterType[File]("Header") })
+	assert.Panics(t, func() { binio.RegisterType[File]("uint8") })
+	assert.Panics(t, func() { binio.RegisterType[File]("MAX_NAME") })
+	assert.Panics(t, func() { binio.RegisterConst("Header", 1) })
+}
+
+type (
+	recursiveSelf struct {
+		Data []byte `bin:"size=sizeof(Self)"`
+	}
+	recursiveA struct {
+		Data []byte `bin:"size=sizeof(RecB)"`
+	}
+	recursiveB struct {
+		Len  uint8
+		Data []byte `bin:"size=sizeof(RecA)"`
+	}
+)
+
+func init() {
+	binio.RegisterType[recursiveSelf]("Self")
+	binio.RegisterType[recursiveA]("RecA")
+	binio.RegisterType[recursiveB]("RecB")
+}
+
+func TestRegisterType_recursive(t *testing.T) {
+	data := make([]byte, 16)
+
+	err := binio.UnmarshalBytes(data, &recursiveSelf{})
+	assert.ErrorIs(t, err, binio.ErrVariableSize)
+	assert.ErrorContains(t, err, "recursive")
+
+	err = binio.UnmarshalBytes(data, &recursiveA{})
+	assert.ErrorIs(t, err, binio.ErrVariableSize)
+	err = binio.UnmarshalBytes(data, &recursiveB{})
+	assert.ErrorIs(t, err, binio.ErrVariableSize)
+
+	_, err = binio.SizeOf[recursiveA]()
+	assert.Error(t, err)
+}
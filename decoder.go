@@ -10,9 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"reflect"
 	"strings"
+	"unsafe"
 
 	"github.com/KlemensWinter/go-binio/expr"
 )
@@ -36,9 +37,26 @@ type (
 	DecodeFunc func(dec *Decoder, v reflect.Value) error
 
 	Decoder struct {
-		rd    io.Reader
-		pos   int64
-		stack []state
+		rd  io.Reader
+		buf []byte // only set for decoders created by NewBytesDecoder
+		pos int64
+
+		// set if rd supports random access; base is the offset of rd
+		// when the decoder was created
+		ra   io.ReaderAt
+		sk   io.Seeker
+		base int64
+
+		stack  []state
+		scopes []fieldFunc // the fields of the structs being decoded
+		ctx    *expr.Context
+
+		aliasStrings bool
+
+		// see OnField and SetLogger
+		onField func(info FieldInfo)
+		logger  *slog.Logger
+		path    []string
 	}
 )
 
@@ -64,14 +82,27 @@ func (dec *Decoder) current() *state {
 }
 
 func NewDecoder(rd io.Reader) *Decoder {
-	return &Decoder{
+	dec := &Decoder{
 		rd:    rd,
 		pos:   0,
 		stack: make([]state, 0, 100),
 	}
+	if ra, ok := rd.(io.ReaderAt); ok {
+		if sk, ok := rd.(io.Seeker); ok {
+			if off, err := sk.Seek(0, io.SeekCurrent); err == nil {
+				dec.ra, dec.sk, dec.base = ra, sk, off
+			}
+		}
+	}
+	return dec
 }
 
 func (dec *Decoder) Skip(n int64) error {
+	if dec.buf != nil {
+		_, err := dec.next(int(n))
+		return err
+	}
+	// Read already advances dec.pos
 	l, err := io.CopyN(io.Discard, dec, n)
 	if err != nil {
 		return err
@@ -79,11 +110,18 @@ func (dec *Decoder) Skip(n int64) error {
 	if l != n {
 		panic("implement me!")
 	}
-	dec.pos += l
 	return nil
 }
 
 func (dec *Decoder) Read(p []byte) (n int, err error) {
+	if dec.buf != nil {
+		if dec.pos >= int64(len(dec.buf)) {
+			return 0, io.EOF
+		}
+		n = copy(p, dec.buf[dec.pos:])
+		dec.pos += int64(n)
+		return
+	}
 	n, err = dec.rd.Read(p)
 	dec.pos += int64(n)
 	return
@@ -132,8 +170,8 @@ func (dec *Decoder) Uint(n int) int {
 }
 
 func (dec *Decoder) stringValue(v reflect.Value, size int) error {
-	buf := make([]byte, size)
-	if _, err := io.ReadAtLeast(dec, buf, size); err != nil {
+	buf, err := dec.readBytes(size)
+	if err != nil {
 		return fmt.Errorf("failed to read string: %w", err)
 	}
 
@@ -141,11 +179,22 @@ func (dec *Decoder) stringValue(v reflect.Value, size int) error {
 		return r == 0
 	})
 
+	if dec.buf != nil && dec.aliasStrings {
+		v.SetString(unsafe.String(unsafe.SliceData(buf), len(buf)))
+		return nil
+	}
 	v.SetString(string(buf))
 	return nil
 }
 
 func (dec *Decoder) skip(v reflect.Value) error {
+	elem := reflect.Invalid
+	if v.Kind() == reflect.Slice {
+		elem = v.Type().Elem().Kind()
+	}
+	if sizedSkip(v.Kind(), elem, dec.current().Field.Tag) {
+		return dec.Skip(int64(dec.current().Size))
+	}
 	n, err := ValueSize(v.Type())
 	if err != nil {
 		return err
@@ -153,6 +202,16 @@ func (dec *Decoder) skip(v reflect.Value) error {
 	return dec.Skip(int64(n))
 }
 
+// sizedSkip reports whether a skipped field is skipped by the size in its
+// tag, which is the case for byte slices and strings; elem is the kind of
+// the elements of slices.
+func sizedSkip(kind, elem reflect.Kind, tag *Tag) bool {
+	if tag.sizeExpr() == nil || tag.IsDynArray() || tag.IsDynString() {
+		return false
+	}
+	return kind == reflect.String || kind == reflect.Slice && elem == reflect.Uint8
+}
+
 func (dec *Decoder) structField(strkt, field reflect.Value, fieldIndex int) (err error) {
 	if dec.current().Condition != nil {
 		cond := expr.Bool(dec.current().Condition)
@@ -166,9 +225,19 @@ func (dec *Decoder) structField(strkt, field reflect.Value, fieldIndex int) (err
 		return dec.skip(field)
 	}
 
+	if isLazy(field.Type()) {
+		size := int64(-1)
+		if tag := dec.current().Field.Tag; tag != nil && tag.Size != nil {
+			size = int64(dec.current().Size)
+		}
+		return dec.lazyValue(field, size)
+	}
+
 	switch field.Kind() {
 	case reflect.Slice:
 		switch {
+		case dec.current().Field.Tag != nil && dec.current().Field.Tag.Until != nil:
+			err = dec.untilSlice(field, dec.fields())
 		case dec.current().Field.Tag.IsDynArray():
 			err = dec.dynArray(field)
 		case dec.current().Field.Tag.IsHoleyArray():
@@ -203,11 +272,19 @@ func (dec *Decoder) sliceValue(v reflect.Value, size int) error {
 	if size >= maxArraySize {
 		panic(fmt.Errorf("array to big! have=%d, max=%d", size, maxArraySize))
 	}
+	if isByteSlice(v.Type()) {
+		return dec.byteSlice(v, size)
+	}
 	sl := reflect.MakeSlice(v.Type(), size, size)
+	trace := dec.traceElems(v.Type())
+	defer dec.saveElem()()
 	for i := 0; i < size; i++ {
+		dec.setElem(i, size, elemValue(sl, i-1))
+		start := dec.enterElem(trace, i)
 		if err := dec.decodeValue(sl.Index(i)); err != nil {
 			return err
 		}
+		dec.leaveElem(trace, start, sl.Index(i))
 	}
 	v.Set(sl)
 	return nil
@@ -224,65 +301,138 @@ func (dec *Decoder) endField() {
 	dec.stack = dec.stack[:len(dec.stack)-1]
 }
 
+// setElem binds the variables of the element i of n elements of the
+// current field before it is decoded: $_index, $_count (-1 if not known
+// in advance) and $_last, the previously decoded element or nil.
+func (dec *Decoder) setElem(i, n int, last any) {
+	cur := dec.current()
+	cur.Set("_index", int64(i))
+	cur.Set("_count", int64(n))
+	cur.Set("_last", last)
+}
+
+// saveElem returns a function which restores the element variables of the
+// current field, which the elements of nested arrays overwrite.
+func (dec *Decoder) saveElem() func() {
+	saved := make(map[string]any, len(elemVars))
+	for _, name := range elemVars {
+		if v, found := dec.current().Get(name); found {
+			saved[name] = v
+		}
+	}
+	return func() {
+		cur := dec.current()
+		for _, name := range elemVars {
+			if v, found := saved[name]; found {
+				cur.Set(name, v)
+			} else {
+				delete(cur.Vars, name)
+			}
+		}
+	}
+}
+
+// elemValue returns the value of the element i of sl for $_last.
+func elemValue(sl reflect.Value, i int) any {
+	if i < 0 {
+		return nil
+	}
+	return fieldValue(sl.Index(i))
+}
+
 func (dec *Decoder) addErrorContext(err error, name string) error {
 	e, ok := err.(*DecodingError)
 	if !ok {
 		e = &DecodingError{
 			Err: err,
-			// Pos: dec.Pos(),
-			Pos: 0,
+			Pos: dec.Pos(),
 		}
 	}
 	e.Path = append([]string{name}, e.Path...)
 	return e
 }
 
-func (dec *Decoder) eval(ex expr.Expr, this reflect.Value) (v any, err error) {
-	if this.Kind() != reflect.Struct {
-		panic("Decoder.eval(): implement me!")
+// fieldFunc resolves %Field references of tag expressions
+type fieldFunc func(name string) (any, bool)
+
+// scope resolves the fields of the struct at depth in the stack of structs
+// being decoded, and the members _parent and _root which refer to the
+// enclosing and the outermost struct.
+type scope struct {
+	dec   *Decoder
+	depth int
+}
+
+func (s scope) Member(name string) (any, bool) {
+	switch name {
+	case "_parent":
+		if s.depth == 0 {
+			return nil, false
+		}
+		return scope{s.dec, s.depth - 1}, true
+	case "_root":
+		return scope{s.dec, 0}, true
 	}
+	return s.dec.scopes[s.depth](name)
+}
 
-	ctx := &expr.Context{
-		GetField: func(name string) (v any, ok bool) {
-			field := this.FieldByName(name)
-			if !field.IsValid() {
-				return nil, false
-			}
-			switch {
-			case field.CanInt():
-				v = field.Int()
-			case field.CanUint():
-				v = int64(field.Uint()) // TODO: check overflow
-			default:
-				v = field.Interface()
-			}
-			return v, true
-		},
-		GetIdent: func(name string) (v any, ok bool) {
-			size := IntSize(name)
-			if size != -1 {
-				return size, true
-			}
-			return "", false
-		},
-		GetVar: func(name string) (v any, ok bool) {
-			return dec.getVar(name)
-		},
+// pushScope pushes the fields of a struct which is about to be decoded and
+// returns a resolver for them, see scope.
+func (dec *Decoder) pushScope(fields fieldFunc) fieldFunc {
+	dec.scopes = append(dec.scopes, fields)
+	return dec.fields()
+}
+
+func (dec *Decoder) popScope() {
+	dec.scopes = dec.scopes[:len(dec.scopes)-1]
+}
+
+// fields returns the resolver of the innermost struct.
+func (dec *Decoder) fields() fieldFunc {
+	return scope{dec, len(dec.scopes) - 1}.Member
+}
+
+// structFields resolves field references to the fields of strkt.
+func structFields(strkt reflect.Value) fieldFunc {
+	return func(name string) (v any, ok bool) {
+		field := strkt.FieldByName(name)
+		if !field.IsValid() {
+			return nil, false
+		}
+		return fieldValue(field), true
 	}
-	v, err = expr.Eval(ctx, ex)
+}
+
+// fieldValue converts field to the value types of the expression
+// evaluator, see expr.Value.
+func fieldValue(field reflect.Value) any {
+	return expr.Value(field)
+}
+
+func (dec *Decoder) eval(tag *Tag, ex expr.Expr, fields fieldFunc) (v any, err error) {
+	p, err := tag.program(ex)
+	if err != nil {
+		panic(err)
+	}
+	if dec.ctx == nil {
+		dec.ctx = &expr.Context{
+			GetIdent: identValue,
+			GetVar:   dec.getVar,
+			GetFunc:  lookupFunc,
+		}
+	}
+	dec.ctx.GetField = fields
+	v, err = p.Eval(dec.ctx)
 	if err != nil {
 		if errors.Is(err, expr.ErrVarNotDefined) {
-			log.Printf("evailable variables:")
-			for i, state := range dec.stack {
-				log.Printf("%d: %v", i, state.Vars)
-			}
+			dec.logVars(ex, err)
 		}
 		panic(err)
 	}
 	return v, err
 }
 
-func (dec *Decoder) evalField(this reflect.Value, f *field) {
+func (dec *Decoder) evalField(this fieldFunc, f *field) {
 	cur := dec.current()
 	cur.Field = f
 
@@ -292,46 +442,52 @@ func (dec *Decoder) evalField(this reflect.Value, f *field) {
 	}
 
 	for key, value := range f.Tag.Vars {
-		v, err := dec.eval(value, this)
+		v, err := dec.eval(f.Tag, value, this)
 		if err != nil {
 			panic(err)
 		}
 		cur.Set(key, v)
 	}
 
+	// the condition is evaluated first, size and ptrs of fields which are
+	// not decoded may refer to values which don't exist
+	if f.HasCondition() {
+		v, err := dec.eval(f.Tag, f.Tag.If, this)
+		if err != nil {
+			panic(err)
+		}
+		cur.Condition = v
+		if !expr.Bool(v) {
+			return
+		}
+	}
+
 	if f.Tag.Size != nil {
-		v, err := dec.eval(f.Tag.Size, this)
+		v, err := dec.eval(f.Tag, f.Tag.Size, this)
 		if err != nil {
 			panic(err)
 		}
+		size, err := expr.Int(v)
+		if err != nil {
+			panic(fmt.Errorf("size %s: %w", f.Tag.Size, err))
+		}
 		if f.Tag.IsDynArray() || f.Tag.IsDynString() {
-			size := v.(int)
 			if size == -1 {
 				panic(fmt.Errorf("invalid count type %q for dynarray/dynstring", v))
 			}
-			cur.Size = size
-		} else {
-			cur.Size = int(v.(int64))
+		} else if size < 0 {
+			panic(fmt.Errorf("size %s: negative size %d", f.Tag.Size, size))
 		}
+		cur.Size = int(size)
 	}
 
 	if f.Tag.Ptrs != nil {
-		ptrs, err := dec.eval(f.Tag.Ptrs, this)
+		ptrs, err := dec.eval(f.Tag, f.Tag.Ptrs, this)
 		if err != nil {
 			panic(err)
 		}
 		cur.Ptrs = ptrs
 	}
-	if f.HasCondition() {
-		v, err := dec.eval(f.Tag.If, this)
-		if err != nil {
-			structName := this.Type().PkgPath()
-			log.Printf("%s.%s ERROR: %#v", structName, f.Name, err)
-			panic(err)
-		}
-		// log.Printf("field %s: condition=%q result=%#v", f.Name, f.Tag.If, v)
-		cur.Condition = v
-	}
 }
 
 func (dec *Decoder) structValue(v reflect.Value) error {
@@ -344,26 +500,61 @@ func (dec *Decoder) structValue(v reflect.Value) error {
 		panic(err)
 	}
 
+	fields := dec.pushScope(structFields(v))
+	defer dec.popScope()
+
+	var (
+		begin = dec.pos
+		spans []Span
+	)
+	if def.hasSpans {
+		spans = make([]Span, len(def.Fields))
+	}
 	for i := 0; i < typ.NumField(); i++ {
 		field := def.Fields[i]
+		if field.isSpan {
+			continue
+		}
 
+		start := dec.enterField(field.Name)
 		dec.beginField()
-		dec.evalField(v, field)
+		dec.evalField(fields, field)
 
-		if err := dec.structField(v, v.Field(i), i); err != nil {
+		err := dec.structField(v, v.Field(i), i)
+		if err == nil && field.Name != "_" {
+			err = dec.valid(fieldValue(v.Field(i)), fields)
+		}
+		if err != nil {
+			dec.logFailed(start, err)
 			err = dec.addErrorContext(err, typ.Field(i).Name)
 			return err
 		}
+		dec.leaveField(start, v.Field(i), field.Name == "_")
 		dec.endField()
+		if spans != nil {
+			spans[i] = Span{Offset: start, Length: dec.pos - start}
+		}
+	}
+	if spans != nil {
+		setSpans(v, def, spans, Span{Offset: begin, Length: dec.pos - begin})
 	}
 	return nil
 }
 
 func (dec *Decoder) arrayValue(v reflect.Value) error {
+	if len(dec.stack) == 0 { // not a field, decoded by Decode
+		dec.beginField()
+		defer dec.endField()
+	}
+	trace := dec.traceElems(v.Type())
+	defer dec.saveElem()()
 	for i := 0; i < v.Len(); i++ {
+		dec.setElem(i, v.Len(), elemValue(v, i-1))
+		start := dec.enterElem(trace, i)
 		if err := dec.decodeValue(v.Index(i)); err != nil {
 			return err
 		}
+		dec.leaveElem(trace, start, v.Index(i))
 	}
 	return nil
 }
@@ -388,6 +579,10 @@ func (dec *Decoder) decodeValue(v reflect.Value) (err error) {
 		return fn(dec, v)
 	}
 
+	if isLazy(v.Type()) {
+		return dec.lazyValue(v, -1)
+	}
+
 	switch v.Kind() {
 	case reflect.Bool:
 		err = binary.Read(dec, binary.LittleEndian, v.Addr().Interface())
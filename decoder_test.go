@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/KlemensWinter/go-binio"
+	"github.com/KlemensWinter/go-binio/expr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -71,6 +72,21 @@ func TestDecodeSkip(t *testing.T) {
 	assert.Equal(t, uint64(math.MaxUint64), f.B)
 }
 
+func TestDecodeSkip_sized(t *testing.T) {
+	// byte slices and strings are skipped by the size in their tag
+	type Foo struct {
+		Len uint8
+		_   []byte `bin:"size=%Len"`
+		_   string `bin:"size=2"`
+		A   uint8
+	}
+	var f Foo
+	err := binio.UnmarshalBytes(pack(uint8(3), []byte("abcde"), uint8(7)), &f)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint8(7), f.A)
+	}
+}
+
 func TestEmbeddedStruct(t *testing.T) {
 	tst := struct {
 		FieldA bool
@@ -154,3 +170,164 @@ func TestDecodeUnmarshaler(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "It works!", val.V)
 }
+
+func TestDecodeUntil(t *testing.T) {
+	type TestData struct {
+		Values []uint16 `bin:"until=$_ == 0"`
+		End    uint8
+	}
+
+	buf := pack(uint16(3), uint16(2), uint16(0), uint8(9))
+
+	var have TestData
+	err := binio.Unmarshal(bytes.NewReader(buf), &have)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []uint16{3, 2, 0}, have.Values)
+		assert.Equal(t, uint8(9), have.End)
+	}
+}
+
+func TestDecodeValid(t *testing.T) {
+	type TestData struct {
+		Magic   [4]byte `bin:"valid=$_ == \"SMPL\""`
+		Version uint8   `bin:"valid=$_ >= 1 && $_ <= %Magic[0]"`
+	}
+
+	var have TestData
+	err := binio.UnmarshalBytes(pack([]byte("SMPL"), uint8(2)), &have)
+	if assert.NoError(t, err) {
+		assert.Equal(t, [4]byte{'S', 'M', 'P', 'L'}, have.Magic)
+		assert.Equal(t, uint8(2), have.Version)
+	}
+
+	err = binio.UnmarshalBytes(pack([]byte("SMPX"), uint8(2)), &have)
+	assert.ErrorIs(t, err, binio.ErrInvalidValue)
+	assert.ErrorContains(t, err, "Magic")
+
+	err = binio.UnmarshalBytes(pack([]byte("SMPL"), uint8(0)), &have)
+	assert.ErrorIs(t, err, binio.ErrInvalidValue)
+}
+
+func TestDecodeMemberAccess(t *testing.T) {
+	type Header struct {
+		Count uint8
+		Sizes [2]uint8
+	}
+	type Entry struct {
+		Name []byte `bin:"size=%_parent.Header.Sizes[1]"`
+		Ext  *uint8 `bin:"if=%_root.Header.Count > 1"`
+	}
+	type File struct {
+		Header  Header
+		Data    []byte  `bin:"size=%Header.Sizes[0]"`
+		Entries []Entry `bin:"size=%Header.Count"`
+	}
+
+	buf := pack(uint8(2), [2]uint8{1, 2}, uint8(9), []byte("ab"), uint8(3), []byte("cd"), uint8(4))
+
+	var have File
+	err := binio.UnmarshalBytes(buf, &have)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte{9}, have.Data)
+		if assert.Len(t, have.Entries, 2) {
+			assert.Equal(t, []byte("cd"), have.Entries[1].Name)
+			assert.Equal(t, uint8(4), *have.Entries[1].Ext)
+		}
+	}
+
+	// _parent of the root struct
+	var root struct {
+		A uint8 `bin:"if=%_parent.A"`
+	}
+	err = binio.UnmarshalBytes(buf, &root)
+	assert.ErrorIs(t, err, expr.ErrFieldNotFound)
+}
+
+func TestDecodeElemVars(t *testing.T) {
+	type Entry struct {
+		ID   uint8
+		Prev *uint8 `bin:"if=$_last != nil"` // not for the first entry
+		Data []byte `bin:"size=%_parent.Lengths[$_index]"`
+		Tail []byte `bin:"size=$_count"`
+	}
+	type File struct {
+		Lengths [2]uint8
+		Entries []Entry `bin:"size=2"`
+		Values  []uint8 `bin:"until=$_index == 2"`
+	}
+
+	buf := pack([2]uint8{1, 2},
+		uint8(10), []byte("a"), []byte("xy"),
+		uint8(11), uint8(10), []byte("bc"), []byte("zz"),
+		[]uint8{1, 2, 3})
+
+	var have File
+	err := binio.UnmarshalBytes(buf, &have)
+	if assert.NoError(t, err) {
+		assert.Nil(t, have.Entries[0].Prev)
+		assert.Equal(t, []byte("a"), have.Entries[0].Data)
+		assert.Equal(t, []byte("xy"), have.Entries[0].Tail)
+		if assert.NotNil(t, have.Entries[1].Prev) {
+			assert.Equal(t, uint8(10), *have.Entries[1].Prev)
+		}
+		assert.Equal(t, []byte("bc"), have.Entries[1].Data)
+		assert.Equal(t, []uint8{1, 2, 3}, have.Values)
+	}
+}
+
+func TestDecodeElemVars_array(t *testing.T) {
+	type Item struct {
+		Data []byte `bin:"size=$_index"`
+	}
+
+	var have [3]Item
+	if err := binio.UnmarshalBytes(pack([]byte("abc")), &have); assert.NoError(t, err) {
+		assert.Equal(t, [3]Item{{}, {[]byte("a")}, {[]byte("bc")}}, have)
+	}
+}
+
+func TestDecodeElemVars_nested(t *testing.T) {
+	// the elements of the rows must not change $_index of Rows
+	type File struct {
+		Rows  [][3]uint16 `bin:"until=$_index == 1"`
+		Count uint8
+	}
+	buf := pack([3]uint16{1, 2, 3}, [3]uint16{4, 5, 6}, uint8(7))
+
+	var have File
+	if err := binio.UnmarshalBytes(buf, &have); assert.NoError(t, err) {
+		assert.Equal(t, [][3]uint16{{1, 2, 3}, {4, 5, 6}}, have.Rows)
+		assert.Equal(t, uint8(7), have.Count)
+	}
+
+	s := new(binio.Schema)
+	s.Root = "File"
+	s.Define("File",
+		&binio.SchemaField{Name: "Rows", Type: "[][3]uint16", Tag: "until=$_index == 1"},
+		&binio.SchemaField{Name: "Count", Type: "uint8"},
+	)
+	v, err := binio.UnmarshalSchema(bytes.NewReader(buf), s)
+	if assert.NoError(t, err) {
+		assert.Len(t, v.(map[string]any)["Rows"], 2)
+		assert.Equal(t, uint8(7), v.(map[string]any)["Count"])
+	}
+}
+
+func TestDecodeCondExpr(t *testing.T) {
+	type Struct struct {
+		Is64  bool
+		Ptr   []byte `bin:"size=%Is64 ? 8 : 4"`
+		Flags uint8  `bin:"if=if(%Is64, true, false)"`
+	}
+
+	var have Struct
+	if err := binio.UnmarshalBytes(pack(true, uint64(1), uint8(7)), &have); assert.NoError(t, err) {
+		assert.Len(t, have.Ptr, 8)
+		assert.Equal(t, uint8(7), have.Flags)
+	}
+	have = Struct{}
+	if err := binio.UnmarshalBytes(pack(false, uint32(1)), &have); assert.NoError(t, err) {
+		assert.Len(t, have.Ptr, 4)
+		assert.Equal(t, uint8(0), have.Flags)
+	}
+}
@@ -0,0 +1,272 @@
+package binio
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Difference is a field whose value differs between the inputs of
+	// Diff.
+	Difference struct {
+		Path []string
+		A, B any // nil for elements missing in one input
+
+		// Length is set if A and B are the lengths of slices of
+		// different lengths. The elements are reported separately.
+		Length bool
+
+		// the spans of the field in the inputs, only known for
+		// annotations
+		SpanA, SpanB *Span
+	}
+
+	differ struct {
+		spansA, spansB map[string]Span
+		order          map[string]int // decode order of annotated fields
+		path           []string
+		res            []*Difference
+		err            error // of the first struct without a definition
+	}
+)
+
+func (d *Difference) String() string {
+	val := func(v any) string {
+		if v == nil {
+			return "<missing>"
+		}
+		if s, ok := v.(string); ok {
+			return strconv.Quote(s)
+		}
+		return fmt.Sprint(v)
+	}
+	span := func(s *Span) string {
+		if s == nil {
+			return "-"
+		}
+		return fmt.Sprintf("0x%x+%d", s.Offset, s.Length)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(JoinPath(d.Path) + ": ")
+	if d.Length {
+		sb.WriteString("length ")
+	}
+	sb.WriteString(val(d.A) + " -> " + val(d.B))
+	if d.SpanA != nil || d.SpanB != nil {
+		sb.WriteString(" (a: " + span(d.SpanA) + ", b: " + span(d.SpanB) + ")")
+	}
+	return sb.String()
+}
+
+// Diff compares two decoded values of the same type field by field and
+// returns the differences in field order. Byte slices and arrays are
+// compared as a whole, other slices element by element.
+//
+// If a and b are annotations (see Annotate and AnnotateSchema), their
+// values are compared and the differences carry the spans of the fields
+// in both inputs. Skipped and span fields are ignored.
+//
+// Maps, like the values of schemas, don't know the order of their fields:
+// their keys are compared in the order they were decoded in if a or b is
+// an annotation, otherwise in sorted order.
+func Diff(a, b any) ([]*Difference, error) {
+	d := &differ{order: make(map[string]int)}
+	if an, ok := a.(*Annotation); ok {
+		a, d.spansA = an.Value, an.spans()
+		d.addOrder(an)
+	}
+	if an, ok := b.(*Annotation); ok {
+		b, d.spansB = an.Value, an.spans()
+		d.addOrder(an)
+	}
+
+	va, vb := derefValue(reflect.ValueOf(a)), derefValue(reflect.ValueOf(b))
+	if va.IsValid() && vb.IsValid() && va.Type() != vb.Type() {
+		return nil, fmt.Errorf("can't compare %s with %s", va.Type(), vb.Type())
+	}
+	d.value(va, vb)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.res, nil
+}
+
+// spans maps the joined paths of all fields to their spans.
+func (a *Annotation) spans() map[string]Span {
+	m := make(map[string]Span, len(a.Fields))
+	for _, f := range a.Fields {
+		m[JoinPath(f.Path)] = f.Span
+	}
+	return m
+}
+
+// derefValue strips pointers and interfaces from v.
+func derefValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	return v
+}
+
+// addOrder records the decode order of the fields of a which are not
+// known yet.
+func (d *differ) addOrder(a *Annotation) {
+	for i, f := range a.Fields {
+		key := JoinPath(f.Path)
+		if _, found := d.order[key]; !found {
+			d.order[key] = i
+		}
+	}
+}
+
+func (d *differ) add(a, b any, length bool) {
+	diff := &Difference{
+		Path:   slices.Clone(d.path),
+		A:      a,
+		B:      b,
+		Length: length,
+	}
+	key := JoinPath(d.path)
+	if s, found := d.spansA[key]; found {
+		diff.SpanA = &s
+	}
+	if s, found := d.spansB[key]; found {
+		diff.SpanB = &s
+	}
+	d.res = append(d.res, diff)
+}
+
+func (d *differ) enter(name string) {
+	d.path = append(d.path, name)
+}
+
+func (d *differ) leave() {
+	d.path = d.path[:len(d.path)-1]
+}
+
+func valueOf(v reflect.Value) any {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+func (d *differ) value(a, b reflect.Value) {
+	a, b = derefValue(a), derefValue(b)
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return
+	case !a.IsValid() || !b.IsValid() || a.Type() != b.Type():
+		d.add(valueOf(a), valueOf(b), false)
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Struct:
+		d.structValue(a, b)
+	case reflect.Map:
+		d.mapValue(a, b)
+	case reflect.Slice, reflect.Array:
+		if a.Type().Elem().Kind() == reflect.Uint8 {
+			if !bytes.Equal(byteContent(a), byteContent(b)) {
+				d.add(valueOf(a), valueOf(b), false)
+			}
+			return
+		}
+		d.elems(a, b)
+	default:
+		if !a.CanInterface() {
+			return
+		}
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			d.add(a.Interface(), b.Interface(), false)
+		}
+	}
+}
+
+func (d *differ) structValue(a, b reflect.Value) {
+	if isLazy(a.Type()) {
+		return // not decoded
+	}
+	def, err := generateStructDef(a.Type())
+	if err != nil {
+		if d.err == nil {
+			d.err = err
+		}
+		return
+	}
+	for i, f := range def.Fields {
+		if f.Name == "_" || f.isSpan || !a.Type().Field(i).IsExported() {
+			continue
+		}
+		d.enter(f.Name)
+		d.value(a.Field(i), b.Field(i))
+		d.leave()
+	}
+}
+
+func (d *differ) mapValue(a, b reflect.Value) {
+	keys := make(map[string]reflect.Value)
+	for _, m := range []reflect.Value{a, b} {
+		for _, k := range m.MapKeys() {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+	}
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// decoded fields first, in decode order
+	order := func(name string) (int, bool) {
+		i, found := d.order[JoinPath(append(slices.Clip(d.path), name))]
+		return i, found
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		oi, iok := order(names[i])
+		oj, jok := order(names[j])
+		if iok && jok {
+			return oi < oj
+		}
+		return iok && !jok
+	})
+	for _, name := range names {
+		d.enter(name)
+		d.value(a.MapIndex(keys[name]), b.MapIndex(keys[name]))
+		d.leave()
+	}
+}
+
+func (d *differ) elems(a, b reflect.Value) {
+	if a.Len() != b.Len() {
+		d.add(a.Len(), b.Len(), true)
+	}
+	for i := 0; i < max(a.Len(), b.Len()); i++ {
+		var ea, eb reflect.Value
+		if i < a.Len() {
+			ea = a.Index(i)
+		}
+		if i < b.Len() {
+			eb = b.Index(i)
+		}
+		d.enter("[" + strconv.Itoa(i) + "]")
+		d.value(ea, eb)
+		d.leave()
+	}
+}
+
+// byteContent returns the content of a byte slice or array.
+func byteContent(v reflect.Value) []byte {
+	if v.Kind() == reflect.Slice {
+		return v.Bytes()
+	}
+	b := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(b), v)
+	return b
+}
@@ -0,0 +1,116 @@
+package binio_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/stretchr/testify/assert"
+)
+
+type diffEntry struct {
+	ID   uint16
+	Name string `bin:"type=dynstring,size=uint8"`
+}
+
+type diffFile struct {
+	Magic   [2]byte
+	Version uint8
+	Entries []diffEntry `bin:"type=dynarray,size=uint8"`
+}
+
+func TestDiff(t *testing.T) {
+	a := diffFile{Version: 1, Entries: []diffEntry{{1, "foo"}}}
+	b := diffFile{Version: 2, Entries: []diffEntry{{1, "bar"}, {2, "baz"}}}
+
+	diffs, err := binio.Diff(a, &b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var res []string
+	for _, d := range diffs {
+		res = append(res, d.String())
+	}
+	assert.Equal(t, []string{
+		"Version: 1 -> 2",
+		"Entries: length 1 -> 2",
+		`Entries[0].Name: "foo" -> "bar"`,
+		"Entries[1]: <missing> -> {2 baz}",
+	}, res)
+
+	_, err = binio.Diff(a, 1)
+	assert.Error(t, err)
+
+	type Invalid struct {
+		A uint8 `bin:"foo=1"`
+	}
+	_, err = binio.Diff(Invalid{1}, Invalid{2})
+	assert.ErrorIs(t, err, binio.ErrInvalidTagOption)
+}
+
+func TestDiff_annotations(t *testing.T) {
+	dataA := pack([2]byte{'D', 'F'}, uint8(1), uint8(1), uint16(7), uint8(1), []byte("x"))
+	dataB := pack([2]byte{'D', 'F'}, uint8(1), uint8(2), uint16(7), uint8(2), []byte("xy"), uint16(8), uint8(1), []byte("z"))
+
+	var fa, fb diffFile
+	a, err := binio.Annotate(bytes.NewReader(dataA), &fa)
+	if !assert.NoError(t, err) {
+		return
+	}
+	b, err := binio.Annotate(bytes.NewReader(dataB), &fb)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	diffs, err := binio.Diff(a, b)
+	if !assert.NoError(t, err) || !assert.Len(t, diffs, 3) {
+		return
+	}
+	assert.Equal(t, "Entries: length 1 -> 2 (a: 0x3+5, b: 0x3+10)", diffs[0].String())
+	assert.Equal(t, `Entries[0].Name: "x" -> "xy" (a: 0x6+2, b: 0x6+3)`, diffs[1].String())
+	assert.Nil(t, diffs[2].SpanA)
+	assert.Equal(t, &binio.Span{Offset: 9, Length: 4}, diffs[2].SpanB)
+}
+
+func TestDiff_schemaOrder(t *testing.T) {
+	s := new(binio.Schema)
+	s.Root = "File"
+	s.Define("File",
+		&binio.SchemaField{Name: "Version", Type: "uint8"},
+		&binio.SchemaField{Name: "Count", Type: "uint8"},
+		&binio.SchemaField{Name: "Body", Type: "[]Entry", Tag: "size=%Count"},
+	)
+	s.Define("Entry",
+		&binio.SchemaField{Name: "Size", Type: "uint8"},
+		&binio.SchemaField{Name: "Kind", Type: "uint8"},
+	)
+
+	a, err := binio.AnnotateSchema(bytes.NewReader(pack(uint8(1), uint8(1), uint8(2), uint8(3))), s)
+	if !assert.NoError(t, err) {
+		return
+	}
+	b, err := binio.AnnotateSchema(bytes.NewReader(pack(uint8(2), uint8(1), uint8(4), uint8(5))), s)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	paths := func(diffs []*binio.Difference) []string {
+		var res []string
+		for _, d := range diffs {
+			res = append(res, binio.JoinPath(d.Path))
+		}
+		return res
+	}
+
+	// annotated maps are compared in schema order
+	diffs, err := binio.Diff(a, b)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"Version", "Body[0].Size", "Body[0].Kind"}, paths(diffs))
+	}
+
+	// plain maps in sorted order
+	diffs, err = binio.Diff(a.Value, b.Value)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"Body[0].Kind", "Body[0].Size", "Version"}, paths(diffs))
+	}
+}
@@ -0,0 +1,306 @@
+package binio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/KlemensWinter/go-binio/expr"
+)
+
+var (
+	ErrNotExportable = errors.New("not exportable")
+)
+
+type (
+	// exporter collects all struct types reachable from a root type, for
+	// the KSY and 010 Editor template exporters.
+	exporter struct {
+		types  []*exportType // dependencies first
+		byType map[reflect.Type]*exportType
+		names  map[string]bool
+	}
+
+	exportType struct {
+		Name string
+		Typ  reflect.Type
+		Def  *structDef
+	}
+)
+
+func newExporter(root reflect.Type) (*exporter, *exportType, error) {
+	ex := &exporter{
+		byType: make(map[reflect.Type]*exportType),
+		names:  make(map[string]bool),
+	}
+	for root.Kind() == reflect.Ptr {
+		root = root.Elem()
+	}
+	if root.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("%w: %s is not a struct", ErrNotExportable, root)
+	}
+	t, err := ex.add(root, root.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+	return ex, t, nil
+}
+
+func (ex *exporter) add(typ reflect.Type, hint string) (*exportType, error) {
+	if t, found := ex.byType[typ]; found {
+		return t, nil
+	}
+	def, err := generateStructDef(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	name := typ.Name()
+	if name == "" {
+		name = hint
+	}
+	if name == "" {
+		name = "Struct"
+	}
+	for i := 2; ex.names[name]; i++ {
+		name = fmt.Sprintf("%s%d", strings.TrimRight(name, "0123456789"), i)
+	}
+	ex.names[name] = true
+
+	t := &exportType{Name: name, Typ: typ, Def: def}
+	ex.byType[typ] = t // before the fields, for recursive types
+
+	for _, f := range def.Fields {
+		if f.isSpan {
+			continue
+		}
+		if elem := exportElem(f.Typ); elem.Kind() == reflect.Struct && !isLazy(elem) {
+			if _, err := ex.add(elem, name+f.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	ex.types = append(ex.types, t)
+	return t, nil
+}
+
+// exportElem strips pointers, slices, arrays and Lazy from typ.
+func exportElem(typ reflect.Type) reflect.Type {
+	for {
+		switch {
+		case isLazy(typ):
+			typ = reflect.New(typ).Interface().(lazyValue).elemType()
+		case typ.Kind() == reflect.Ptr, typ.Kind() == reflect.Slice, typ.Kind() == reflect.Array:
+			typ = typ.Elem()
+		default:
+			return typ
+		}
+	}
+}
+
+// exportBase strips pointers and Lazy from typ.
+func exportBase(typ reflect.Type) reflect.Type {
+	for {
+		switch {
+		case isLazy(typ):
+			typ = reflect.New(typ).Interface().(lazyValue).elemType()
+		case typ.Kind() == reflect.Ptr:
+			typ = typ.Elem()
+		default:
+			return typ
+		}
+	}
+}
+
+// intIdent returns the integer type named by e, which is used as the
+// size of dynarray and dynstring fields.
+func intIdent(e expr.Expr) (reflect.Kind, error) {
+	if id, ok := e.(*expr.Ident); ok {
+		if kind, found := intNames[id.Name]; found {
+			return kind, nil
+		}
+	}
+	return reflect.Invalid, fmt.Errorf("%w: invalid count type %s", ErrNotExportable, e)
+}
+
+// snakeName converts a Go name like NumEntries or HTTPHeader into
+// num_entries or http_header.
+func snakeName(name string) string {
+	rs := []rune(name)
+	var sb strings.Builder
+	for i, r := range rs {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(rs[i-1]) || unicode.IsDigit(rs[i-1]) ||
+				i+1 < len(rs) && unicode.IsLower(rs[i+1]) && unicode.IsUpper(rs[i-1])) {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// exportSyntax formats expressions for the target language of an exporter.
+type exportSyntax struct {
+	Field  func(name string) string
+	Member func(name string) string // names in member access, optional
+	String func(s string) string    // string literals, optional
+	Vars   map[string]string
+	And    string
+	Or     string
+	Not    string
+}
+
+var exportOps = map[expr.Token]string{
+	expr.ADD: "+",
+	expr.SUB: "-",
+	expr.MUL: "*",
+	expr.QUO: "/",
+	expr.REM: "%",
+	expr.LSS: "<",
+	expr.GTR: ">",
+	expr.EQL: "==",
+	expr.NEQ: "!=",
+	expr.LEQ: "<=",
+	expr.GEQ: ">=",
+}
+
+// exportPrec returns the precedence of a binary operator in C like
+// languages.
+func exportPrec(op expr.Token) int {
+	switch op {
+	case expr.LOR:
+		return 1
+	case expr.LAND:
+		return 2
+	case expr.EQL, expr.NEQ:
+		return 3
+	case expr.ADD, expr.SUB:
+		return 5
+	case expr.MUL, expr.QUO, expr.REM:
+		return 6
+	default:
+		return 4
+	}
+}
+
+func (syn *exportSyntax) format(e expr.Expr) (string, error) {
+	switch e := e.(type) {
+	case *expr.Field:
+		return syn.Field(e.Name), nil
+	case *expr.Var:
+		if v, found := syn.Vars[e.Name]; found {
+			return v, nil
+		}
+		return "", fmt.Errorf("%w: variable %s", ErrNotExportable, e)
+	case *expr.Ident:
+		if v, found := lookupConst(e.Name); found {
+			return syn.format(&expr.Const{Value: v})
+		}
+	case *expr.Const:
+		switch v := e.Value.(type) {
+		case bool, int64, float32, float64:
+			return fmt.Sprint(v), nil
+		case string:
+			if syn.String != nil {
+				return syn.String(v), nil
+			}
+		}
+	case *expr.CondExpr:
+		var parts [3]string
+		for i, x := range []expr.Expr{e.Cond, e.Then, e.Else} {
+			s, err := syn.operand(x, 0, i < 2)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return fmt.Sprintf("%s ? %s : %s", parts[0], parts[1], parts[2]), nil
+	case *expr.Selector:
+		x, err := syn.operand(e.X, 8, false)
+		if err != nil {
+			return "", err
+		}
+		if syn.Member != nil {
+			return x + "." + syn.Member(e.Name), nil
+		}
+		return x + "." + e.Name, nil
+	case *expr.Index:
+		x, err := syn.operand(e.X, 8, false)
+		if err != nil {
+			return "", err
+		}
+		i, err := syn.format(e.Index)
+		if err != nil {
+			return "", err
+		}
+		return x + "[" + i + "]", nil
+	case *expr.UnaryExpr:
+		x, err := syn.operand(e.X, 7, false)
+		if err != nil {
+			return "", err
+		}
+		switch e.Op {
+		case expr.NOT:
+			return syn.Not + x, nil
+		case expr.SUB:
+			return "-" + x, nil
+		}
+	case *expr.BinExpr:
+		prec := exportPrec(e.Op)
+		lhs, err := syn.operand(e.Lhs, prec, false)
+		if err != nil {
+			return "", err
+		}
+		rhs, err := syn.operand(e.Rhs, prec, true)
+		if err != nil {
+			return "", err
+		}
+		op, found := exportOps[e.Op]
+		switch e.Op {
+		case expr.LAND:
+			op, found = syn.And, true
+		case expr.LOR:
+			op, found = syn.Or, true
+		}
+		if found {
+			return fmt.Sprintf("%s %s %s", lhs, op, rhs), nil
+		}
+	}
+	return "", fmt.Errorf("%w: expression %s", ErrNotExportable, e)
+}
+
+// operand formats the operand of an operator with the precedence prec and
+// adds parentheses where necessary.
+func (syn *exportSyntax) operand(e expr.Expr, prec int, right bool) (string, error) {
+	s, err := syn.format(e)
+	if err != nil {
+		return "", err
+	}
+	switch e := e.(type) {
+	case *expr.BinExpr:
+		p := exportPrec(e.Op)
+		if p < prec || right && p == prec {
+			s = "(" + s + ")"
+		}
+	case *expr.CondExpr:
+		if prec > 0 || right {
+			s = "(" + s + ")"
+		}
+	}
+	return s, nil
+}
+
+// skipSize reports whether the skipped field f is skipped by the size in
+// its tag, see sizedSkip.
+func skipSize(f *field) bool {
+	typ := exportBase(f.Typ)
+	elem := reflect.Invalid
+	if typ.Kind() == reflect.Slice {
+		elem = typ.Elem().Kind()
+	}
+	return sizedSkip(typ.Kind(), elem, f.Tag)
+}
@@ -0,0 +1,235 @@
+package binio
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/KlemensWinter/go-binio/expr"
+)
+
+var (
+	btPrims = map[reflect.Kind]string{
+		reflect.Bool:    "ubyte",
+		reflect.Uint8:   "ubyte",
+		reflect.Uint16:  "uint16",
+		reflect.Uint32:  "uint32",
+		reflect.Uint64:  "uint64",
+		reflect.Int8:    "byte",
+		reflect.Int16:   "int16",
+		reflect.Int32:   "int32",
+		reflect.Int64:   "int64",
+		reflect.Float32: "float",
+		reflect.Float64: "double",
+	}
+)
+
+type btExporter struct {
+	*exporter
+	buf bytes.Buffer
+	pad int
+}
+
+// ExportBT generates an 010 Editor binary template (.bt) for the struct
+// type typ and all types it references.
+//
+// Fields of type dynarray and dynstring get an additional count field
+// named <field>_count or <field>_len. Tag variables and expressions that
+// have no equivalent in the template language are reported as
+// ErrNotExportable.
+func ExportBT(typ reflect.Type) ([]byte, error) {
+	ex, root, err := newExporter(typ)
+	if err != nil {
+		return nil, err
+	}
+	b := &btExporter{exporter: ex}
+
+	fmt.Fprintf(&b.buf, "// 010 Editor template for %s\n\n", root.Name)
+	b.buf.WriteString("LittleEndian();\n")
+	for _, t := range ex.types {
+		b.buf.WriteString("\ntypedef struct {\n")
+		for i, f := range t.Def.Fields {
+			if f.isSpan {
+				continue
+			}
+			if err := b.field(f); err != nil {
+				return nil, fmt.Errorf("%s: field %d (%s): %w", t.Name, i, f.Name, err)
+			}
+		}
+		fmt.Fprintf(&b.buf, "} %s;\n", t.Name)
+	}
+	fmt.Fprintf(&b.buf, "\n%s %s;\n", root.Name, snakeName(root.Name))
+	return b.buf.Bytes(), nil
+}
+
+func (b *btExporter) expr(e expr.Expr, vars map[string]string) (string, error) {
+	for _, name := range expr.Fields(e) {
+		if isScopeRef(name) {
+			return "", fmt.Errorf("%w: %%%s in 010 Editor templates", ErrNotExportable, name)
+		}
+	}
+	syn := &exportSyntax{
+		Field: func(name string) string { return name },
+		Vars:  vars,
+		And:   "&&",
+		Or:    "||",
+		Not:   "!",
+	}
+	return syn.format(e)
+}
+
+func (b *btExporter) typeName(typ reflect.Type) (string, error) {
+	typ = exportBase(typ)
+	if name, found := btPrims[typ.Kind()]; found {
+		return name, nil
+	}
+	if t, found := b.byType[typ]; found {
+		return t.Name, nil
+	}
+	return "", fmt.Errorf("%w: type %s", ErrNotExportable, typ)
+}
+
+func (b *btExporter) field(f *field) error {
+	tag := f.Tag
+	if tag == nil {
+		tag = &Tag{}
+	}
+	if len(tag.Vars) > 0 {
+		return fmt.Errorf("%w: tag variables", ErrNotExportable)
+	}
+	if tag.Valid != nil {
+		return fmt.Errorf("%w: valid", ErrNotExportable)
+	}
+
+	var lines []string
+	add := func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	count := func(suffix string) (string, error) {
+		kind, err := intIdent(tag.Size)
+		if err != nil {
+			return "", err
+		}
+		add("%s %s%s;", btPrims[kind], f.Name, suffix)
+		return f.Name + suffix, nil
+	}
+
+	typ := exportBase(f.Typ)
+	switch {
+	case f.Name == "_" && skipSize(f):
+		n, err := b.expr(tag.Size, nil)
+		if err != nil {
+			return err
+		}
+		b.pad++
+		add("ubyte padding%d[%s];", b.pad, n)
+
+	case f.Name == "_":
+		n, err := ValueSize(f.Typ)
+		if err != nil {
+			return err
+		}
+		b.pad++
+		add("ubyte padding%d[%d];", b.pad, n)
+
+	case typ.Kind() == reflect.Array:
+		elem, err := b.typeName(typ.Elem())
+		if err != nil {
+			return err
+		}
+		add("%s %s[%d];", elem, f.Name, typ.Len())
+
+	case typ.Kind() == reflect.String:
+		var (
+			n   string
+			err error
+		)
+		if tag.IsDynString() {
+			n, err = count("_len")
+		} else {
+			n, err = b.expr(tag.Size, nil)
+		}
+		if err != nil {
+			return err
+		}
+		add("char %s[%s];", f.Name, n)
+
+	case typ.Kind() == reflect.Slice:
+		elem, err := b.typeName(typ.Elem())
+		if err != nil {
+			return err
+		}
+		var n string
+		switch {
+		case tag.Until != nil:
+			i := f.Name + "_n"
+			cond, err := b.expr(tag.Until, map[string]string{"_": f.Name + "[" + i + " - 1]"})
+			if err != nil {
+				return err
+			}
+			add("local int %s = 0;", i)
+			add("do {")
+			add("    %s %s;", elem, f.Name)
+			add("    %s++;", i)
+			add("} while (!(%s));", cond)
+		case tag.IsHoleyArray():
+			ptrs, ok := tag.Ptrs.(*expr.Field)
+			if !ok {
+				return fmt.Errorf("%w: ptrs must be a field", ErrNotExportable)
+			}
+			i := f.Name + "_i"
+			add("local int %s;", i)
+			add("for (%s = 0; %s < sizeof(%s) / sizeof(%s[0]); %s++) {", i, i, ptrs.Name, ptrs.Name, i)
+			add("    if (%s[%s] != 0)", ptrs.Name, i)
+			add("        %s %s;", elem, f.Name)
+			add("}")
+		case tag.IsDynArray():
+			n, err = count("_count")
+		case tag.Size != nil:
+			n, err = b.expr(tag.Size, nil)
+		default:
+			return ErrMissingSize
+		}
+		if err != nil {
+			return err
+		}
+		if n != "" {
+			add("%s %s[%s];", elem, f.Name, n)
+		}
+
+	default:
+		t, err := b.typeName(typ)
+		if err != nil {
+			return err
+		}
+		if isLazy(f.Typ) && tag.Size != nil {
+			// the value is in a block of size bytes, like in KSY
+			n, err := b.expr(tag.Size, nil)
+			if err != nil {
+				return err
+			}
+			add("local int64 %s_start = FTell();", f.Name)
+			add("%s %s;", t, f.Name)
+			add("FSeek(%s_start + (%s));", f.Name, n)
+			break
+		}
+		add("%s %s;", t, f.Name)
+	}
+
+	indent := "    "
+	if tag.If != nil {
+		cond, err := b.expr(tag.If, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b.buf, "%sif (%s) {\n", indent, cond)
+		indent += "    "
+	}
+	for _, line := range lines {
+		b.buf.WriteString(indent + line + "\n")
+	}
+	if tag.If != nil {
+		b.buf.WriteString("    }\n")
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package binio
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/KlemensWinter/go-binio/expr"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ksyPrims = map[reflect.Kind]string{
+		reflect.Bool:    "u1",
+		reflect.Uint8:   "u1",
+		reflect.Uint16:  "u2",
+		reflect.Uint32:  "u4",
+		reflect.Uint64:  "u8",
+		reflect.Int8:    "s1",
+		reflect.Int16:   "s2",
+		reflect.Int32:   "s4",
+		reflect.Int64:   "s8",
+		reflect.Float32: "f4",
+		reflect.Float64: "f8",
+	}
+)
+
+type (
+	ksyDoc struct {
+		Meta   *ksyMeta           `yaml:"meta,omitempty"`
+		Params []*ksyParam        `yaml:"params,omitempty"`
+		Seq    []*ksyAttr         `yaml:"seq"`
+		Types  map[string]*ksyDoc `yaml:"types,omitempty"`
+	}
+
+	ksyMeta struct {
+		ID     string `yaml:"id"`
+		Endian string `yaml:"endian"`
+	}
+
+	ksyParam struct {
+		ID   string `yaml:"id"`
+		Type string `yaml:"type"`
+	}
+
+	ksyAttr struct {
+		ID          string    `yaml:"id,omitempty"`
+		Type        string    `yaml:"type,omitempty"`
+		Size        any       `yaml:"size,omitempty"`
+		Encoding    string    `yaml:"encoding,omitempty"`
+		Repeat      string    `yaml:"repeat,omitempty"`
+		RepeatExpr  any       `yaml:"repeat-expr,omitempty"`
+		RepeatUntil string    `yaml:"repeat-until,omitempty"`
+		If          string    `yaml:"if,omitempty"`
+		Valid       *ksyValid `yaml:"valid,omitempty"`
+	}
+
+	ksyValid struct {
+		Expr string `yaml:"expr"`
+	}
+
+	ksyExporter struct {
+		*exporter
+		doc *ksyDoc
+	}
+)
+
+// ExportKSY generates a Kaitai Struct description (.ksy) of the struct
+// type typ and all types it references.
+//
+// Fields of type dynarray and dynstring get an additional count field
+// named <field>_count or <field>_len. Tag variables and expressions that
+// have no equivalent in Kaitai Struct are reported as ErrNotExportable.
+func ExportKSY(typ reflect.Type) ([]byte, error) {
+	ex, root, err := newExporter(typ)
+	if err != nil {
+		return nil, err
+	}
+	k := &ksyExporter{
+		exporter: ex,
+		doc: &ksyDoc{
+			Meta: &ksyMeta{ID: snakeName(root.Name), Endian: "le"},
+		},
+	}
+	for _, t := range ex.types {
+		d := k.doc
+		if t != root {
+			d = &ksyDoc{}
+			k.addType(snakeName(t.Name), d)
+		}
+		if err := k.seq(d, t); err != nil {
+			return nil, fmt.Errorf("%s: %w", t.Name, err)
+		}
+	}
+	return yaml.Marshal(k.doc)
+}
+
+func (k *ksyExporter) addType(name string, d *ksyDoc) {
+	if k.doc.Types == nil {
+		k.doc.Types = make(map[string]*ksyDoc)
+	}
+	k.doc.Types[name] = d
+}
+
+func (k *ksyExporter) expr(e expr.Expr, parent string) (any, error) {
+	if c, ok := e.(*expr.Const); ok {
+		if n, ok := c.Value.(int64); ok {
+			return n, nil
+		}
+	}
+	return k.syntax(parent).format(e)
+}
+
+func (k *ksyExporter) syntax(parent string) *exportSyntax {
+	return &exportSyntax{
+		Field:  func(name string) string { return parent + snakeName(name) },
+		Member: snakeName,
+		Vars:   map[string]string{"_": "_"},
+		And:    "and",
+		Or:     "or",
+		Not:    "not ",
+	}
+}
+
+// ksyBytes formats s as a byte array literal, which byte arrays are
+// compared with.
+func ksyBytes(s string) string {
+	parts := make([]string, len(s))
+	for i := 0; i < len(s); i++ {
+		parts[i] = fmt.Sprintf("0x%02x", s[i])
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func (k *ksyExporter) exprString(e expr.Expr, parent string) (string, error) {
+	v, err := k.expr(e, parent)
+	return fmt.Sprint(v), err
+}
+
+func (k *ksyExporter) typeName(typ reflect.Type) (string, error) {
+	typ = exportBase(typ)
+	if name, found := ksyPrims[typ.Kind()]; found {
+		return name, nil
+	}
+	if t, found := k.byType[typ]; found {
+		return snakeName(t.Name), nil
+	}
+	return "", fmt.Errorf("%w: type %s", ErrNotExportable, typ)
+}
+
+func (k *ksyExporter) seq(d *ksyDoc, t *exportType) error {
+	for i, f := range t.Def.Fields {
+		if f.isSpan {
+			continue
+		}
+		if f.Name == "_" && skipSize(f) {
+			n, err := k.expr(f.Tag.Size, "")
+			if err != nil {
+				return err
+			}
+			d.Seq = append(d.Seq, &ksyAttr{Size: n})
+			continue
+		}
+		if f.Name == "_" {
+			n, err := ValueSize(f.Typ)
+			if err != nil {
+				return err
+			}
+			d.Seq = append(d.Seq, &ksyAttr{Size: int64(n)})
+			continue
+		}
+		attrs, err := k.field(t, f)
+		if err != nil {
+			return fmt.Errorf("field %d (%s): %w", i, f.Name, err)
+		}
+		d.Seq = append(d.Seq, attrs...)
+	}
+	return nil
+}
+
+func (k *ksyExporter) field(t *exportType, f *field) ([]*ksyAttr, error) {
+	var (
+		err   error
+		tag   = f.Tag
+		name  = snakeName(f.Name)
+		a     = &ksyAttr{ID: name}
+		attrs []*ksyAttr
+	)
+	if tag == nil {
+		tag = &Tag{}
+	}
+	if len(tag.Vars) > 0 {
+		return nil, fmt.Errorf("%w: tag variables", ErrNotExportable)
+	}
+	if tag.If != nil {
+		if a.If, err = k.exprString(tag.If, ""); err != nil {
+			return nil, err
+		}
+	}
+	if tag.Valid != nil {
+		// $_ is the value of the field, strings compared with byte
+		// arrays must be byte arrays too
+		syn := k.syntax("")
+		syn.String = strconv.Quote
+		if typ := exportBase(f.Typ); (typ.Kind() == reflect.Array || typ.Kind() == reflect.Slice) && typ.Elem().Kind() == reflect.Uint8 {
+			syn.String = ksyBytes
+		}
+		a.Valid = &ksyValid{}
+		if a.Valid.Expr, err = syn.format(tag.Valid); err != nil {
+			return nil, err
+		}
+	}
+
+	// count adds the count field of dynarray and dynstring fields
+	count := func(suffix string) (string, error) {
+		kind, err := intIdent(tag.Size)
+		if err != nil {
+			return "", err
+		}
+		attrs = append(attrs, &ksyAttr{
+			ID:   name + suffix,
+			Type: ksyPrims[kind],
+			If:   a.If,
+		})
+		return name + suffix, nil
+	}
+
+	typ := exportBase(f.Typ)
+	switch typ.Kind() {
+	case reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			a.Size = int64(typ.Len())
+			break
+		}
+		if a.Type, err = k.typeName(typ.Elem()); err != nil {
+			return nil, err
+		}
+		a.Repeat = "expr"
+		a.RepeatExpr = int64(typ.Len())
+
+	case reflect.String:
+		a.Type = "str"
+		a.Encoding = "ASCII"
+		if tag.IsDynString() {
+			a.Size, err = count("_len")
+		} else {
+			a.Size, err = k.expr(tag.Size, "")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+	case reflect.Slice:
+		elem := typ.Elem()
+		var n any
+		switch {
+		case tag.Until != nil:
+			if a.Type, err = k.typeName(elem); err != nil {
+				return nil, err
+			}
+			a.Repeat = "until"
+			if a.RepeatUntil, err = k.exprString(tag.Until, ""); err != nil {
+				return nil, err
+			}
+			return append(attrs, a), nil
+		case tag.IsHoleyArray():
+			return append(attrs, a), k.holeyArray(t, f, a)
+		case tag.IsDynArray():
+			n, err = count("_count")
+		case tag.Size != nil:
+			n, err = k.expr(tag.Size, "")
+		default:
+			return nil, ErrMissingSize
+		}
+		if err != nil {
+			return nil, err
+		}
+		if elem.Kind() == reflect.Uint8 {
+			a.Size = n
+			break
+		}
+		if a.Type, err = k.typeName(elem); err != nil {
+			return nil, err
+		}
+		a.Repeat = "expr"
+		a.RepeatExpr = n
+
+	default:
+		if a.Type, err = k.typeName(typ); err != nil {
+			return nil, err
+		}
+		if isLazy(f.Typ) && tag.Size != nil {
+			if a.Size, err = k.expr(tag.Size, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return append(attrs, a), nil
+}
+
+// holeyArray describes a holeyarray as a repetition of a parametric type,
+// which only contains a value if the matching entry of ptrs is not 0.
+func (k *ksyExporter) holeyArray(t *exportType, f *field, a *ksyAttr) error {
+	if _, ok := f.Tag.Ptrs.(*expr.Field); !ok {
+		return fmt.Errorf("%w: ptrs must be a field", ErrNotExportable)
+	}
+	ptrs, err := k.exprString(f.Tag.Ptrs, "")
+	if err != nil {
+		return err
+	}
+	elem, err := k.typeName(exportBase(f.Typ).Elem())
+	if err != nil {
+		return err
+	}
+
+	item := snakeName(t.Name) + "_" + a.ID + "_item"
+	k.addType(item, &ksyDoc{
+		Params: []*ksyParam{{ID: "i", Type: "s4"}},
+		Seq: []*ksyAttr{{
+			ID:   "value",
+			Type: elem,
+			If:   "_parent." + ptrs + "[i] != 0",
+		}},
+	})
+	a.Type = item + "(_index)"
+	a.Repeat = "expr"
+	a.RepeatExpr = ptrs + ".size"
+	return nil
+}
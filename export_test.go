@@ -0,0 +1,230 @@
+package binio_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/KlemensWinter/go-binio/ksy"
+	"github.com/stretchr/testify/assert"
+)
+
+type exportEntry struct {
+	ID   uint32
+	Name string `bin:"type=dynstring,size=uint8"`
+}
+
+type exportFile struct {
+	Magic   [4]byte
+	Version uint16
+	_       [2]byte
+	Count   uint32
+	Entries []exportEntry `bin:"size=%Count"`
+	HasExt  bool
+	Ext     uint64  `bin:"if=%HasExt && %Version > 1"`
+	Tail    []uint8 `bin:"until=$_ == 0"`
+}
+
+func exportTestData() []byte {
+	return pack(
+		[]byte("TEST"), uint16(2), [2]byte{}, uint32(2),
+		uint32(7), uint8(3), []byte("foo"),
+		uint32(8), uint8(1), []byte("x"),
+		true, uint64(99),
+		uint8(1), uint8(0),
+	)
+}
+
+func TestExportKSY(t *testing.T) {
+	src, err := binio.ExportKSY(reflect.TypeOf(exportFile{}))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, want := range []string{
+		"id: export_file",
+		"endian: le",
+		"- id: entries\n      type: export_entry\n      repeat: expr\n      repeat-expr: count",
+		"- id: name_len\n              type: u1",
+		"- id: name\n              type: str\n              size: name_len",
+		"if: has_ext and version > 1",
+		"repeat-until: _ == 0",
+	} {
+		assert.Contains(t, string(src), want)
+	}
+
+	// decode the data with the exported description
+	spec, err := ksy.Parse(src)
+	if !assert.NoError(t, err) {
+		return
+	}
+	s, err := spec.Schema()
+	if !assert.NoError(t, err) {
+		return
+	}
+	v, err := binio.UnmarshalSchema(bytes.NewReader(exportTestData()), s)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var f exportFile
+	if !assert.NoError(t, binio.Unmarshal(bytes.NewReader(exportTestData()), &f)) {
+		return
+	}
+	m := v.(map[string]any)
+	assert.Equal(t, f.Magic[:], m["Magic"])
+	assert.Equal(t, f.Ext, m["Ext"])
+	assert.Equal(t, []any{uint8(1), uint8(0)}, m["Tail"])
+	entries := m["Entries"].([]any)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "foo", entries[0].(map[string]any)["Name"])
+	}
+}
+
+func TestExportKSY_holeyArray(t *testing.T) {
+	type Struct struct {
+		Ptrs   [4]uint32
+		Values []uint16 `bin:"type=holeyarray,ptrs=%Ptrs"`
+	}
+
+	src, err := binio.ExportKSY(reflect.TypeOf(Struct{}))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "type: struct_values_item(_index)")
+		assert.Contains(t, string(src), "repeat-expr: ptrs.size")
+		assert.Contains(t, string(src), "if: _parent.ptrs[i] != 0")
+	}
+}
+
+func TestExportBT(t *testing.T) {
+	src, err := binio.ExportBT(reflect.TypeOf(exportFile{}))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, `// 010 Editor template for exportFile
+
+LittleEndian();
+
+typedef struct {
+    uint32 ID;
+    ubyte Name_len;
+    char Name[Name_len];
+} exportEntry;
+
+typedef struct {
+    ubyte Magic[4];
+    uint16 Version;
+    ubyte padding1[2];
+    uint32 Count;
+    exportEntry Entries[Count];
+    ubyte HasExt;
+    if (HasExt && Version > 1) {
+        uint64 Ext;
+    }
+    local int Tail_n = 0;
+    do {
+        ubyte Tail;
+        Tail_n++;
+    } while (!(Tail[Tail_n - 1] == 0));
+} exportFile;
+
+exportFile export_file;
+`, string(src))
+}
+
+func TestExport_errors(t *testing.T) {
+	type Struct struct {
+		Foo   byte
+		Inner struct {
+			Data []byte `bin:"size=$size"`
+		} `bin:"$size=%Foo"`
+	}
+
+	_, err := binio.ExportKSY(reflect.TypeOf(Struct{}))
+	assert.ErrorIs(t, err, binio.ErrNotExportable)
+	_, err = binio.ExportBT(reflect.TypeOf(Struct{}))
+	assert.ErrorIs(t, err, binio.ErrNotExportable)
+	_, err = binio.ExportBT(reflect.TypeOf(0))
+	assert.ErrorIs(t, err, binio.ErrNotExportable)
+}
+
+func TestExport_cond(t *testing.T) {
+	type Struct struct {
+		Wide uint8
+		Data []byte `bin:"size=%Wide > 1 ? 8 : %Wide ? 4 : 2"`
+	}
+
+	src, err := binio.ExportKSY(reflect.TypeOf(Struct{}))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "size: 'wide > 1 ? 8 : wide ? 4 : 2'")
+	}
+	src, err = binio.ExportBT(reflect.TypeOf(Struct{}))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "ubyte Data[Wide > 1 ? 8 : Wide ? 4 : 2];")
+	}
+}
+
+func TestExport_arith(t *testing.T) {
+	type Struct struct {
+		Count uint8
+		Data  []byte `bin:"size=(%Count + 1) * 2 - %Count % 3"`
+	}
+
+	src, err := binio.ExportKSY(reflect.TypeOf(Struct{}))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "size: (count + 1) * 2 - count % 3")
+	}
+	src, err = binio.ExportBT(reflect.TypeOf(Struct{}))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "ubyte Data[(Count + 1) * 2 - Count % 3];")
+	}
+}
+
+func TestExport_sizedSkip(t *testing.T) {
+	type Struct struct {
+		Len uint8
+		_   []byte `bin:"size=%Len"`
+	}
+
+	src, err := binio.ExportKSY(reflect.TypeOf(Struct{}))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "- size: len")
+	}
+	src, err = binio.ExportBT(reflect.TypeOf(Struct{}))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "ubyte padding1[Len];")
+	}
+}
+
+func TestExport_valid(t *testing.T) {
+	type Struct struct {
+		Magic [2]byte `bin:"valid=$_ == \"\\x89P\""`
+		Name  string  `bin:"size=2,valid=$_ != \"ab\""`
+	}
+
+	src, err := binio.ExportKSY(reflect.TypeOf(Struct{}))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "expr: _ == [0x89, 0x50]")
+		assert.Contains(t, string(src), `expr: _ != "ab"`)
+	}
+}
+
+func TestExportBT_lazy(t *testing.T) {
+	src, err := binio.ExportBT(reflect.TypeOf(lazyEntry{}))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "local int64 Data_start = FTell();\n    lazyPayload Data;\n    FSeek(Data_start + (Len));")
+	}
+}
+
+func TestExportBT_scopeRefs(t *testing.T) {
+	type Child struct {
+		Data []byte `bin:"size=%_parent.Len"`
+	}
+	type Parent struct {
+		Len   uint8
+		Child Child
+	}
+	_, err := binio.ExportBT(reflect.TypeOf(Parent{}))
+	assert.ErrorIs(t, err, binio.ErrNotExportable)
+}
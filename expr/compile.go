@@ -0,0 +1,637 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Type is the static type of an expression, see Compile.
+type Type int
+
+const (
+	TypeUnknown Type = iota // only known when the expression is evaluated
+	TypeNil
+	TypeInt
+	TypeFloat
+	TypeBool
+	TypeString // strings and byte slices and arrays
+)
+
+var typeNames = [...]string{
+	TypeUnknown: "unknown",
+	TypeNil:     "nil",
+	TypeInt:     "integer",
+	TypeFloat:   "float64",
+	TypeBool:    "bool",
+	TypeString:  "string",
+}
+
+func (t Type) String() string {
+	if t >= 0 && int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+func (t Type) isNumber() bool { return t == TypeInt || t == TypeFloat }
+
+// TypeOf returns the static type of values of the Go type t.
+func TypeOf(t reflect.Type) Type {
+	if t == nil {
+		return TypeUnknown
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return TypeInt
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat
+	case reflect.Bool:
+		return TypeBool
+	case reflect.String:
+		return TypeString
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return TypeString
+		}
+	}
+	return TypeUnknown
+}
+
+// typeOfValue returns the static type of the value v.
+func typeOfValue(v any) Type {
+	switch v.(type) {
+	case nil:
+		return TypeNil
+	case int64, uint64:
+		return TypeInt
+	case float64:
+		return TypeFloat
+	case bool:
+		return TypeBool
+	case string:
+		return TypeString
+	}
+	return TypeOf(reflect.TypeOf(v))
+}
+
+// Env describes what is known about the names in an expression when it is
+// compiled. All fields are optional.
+type Env struct {
+	// Field returns the Go type of the field name; the type may be nil if
+	// it is not known. Fields which are not found are an error.
+	Field func(name string) (reflect.Type, bool)
+
+	// Ident resolves identifiers to constants. Identifiers which are not
+	// resolved are looked up with Context.GetIdent when evaluated.
+	Ident func(name string) (any, bool)
+
+	// Func resolves functions in addition to the builtins. Functions which
+	// are not resolved are looked up with Context.GetFunc when evaluated.
+	// Like the builtins, the functions must not have side effects: calls
+	// with constant arguments are evaluated by Compile.
+	Func func(name string) (Func, bool)
+}
+
+// Program is a compiled expression.
+type Program struct {
+	Expr Expr
+	Type Type
+
+	run   func(ctx *Context) (any, error)
+	konst bool
+	value any
+}
+
+// Compile type checks e and compiles it to a Program, which evaluates e
+// without walking the syntax tree. Constant subexpressions are evaluated
+// once, errors in them are reported by Compile.
+func Compile(e Expr, env *Env) (*Program, error) {
+	if env == nil {
+		env = &Env{}
+	}
+	c := &compiler{env: env}
+	n, err := c.compile(e)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{Expr: e, Type: n.typ, run: n.run, konst: n.konst, value: n.value}, nil
+}
+
+// Eval evaluates the program; ctx may be nil for constant programs.
+func (p *Program) Eval(ctx *Context) (any, error) {
+	if p.konst {
+		return p.value, nil
+	}
+	if ctx == nil {
+		ctx = &Context{}
+	}
+	return p.run(ctx)
+}
+
+// Const returns the value of a constant program.
+func (p *Program) Const() (any, bool) {
+	return p.value, p.konst
+}
+
+func (p *Program) String() string {
+	return p.Expr.String()
+}
+
+type compiler struct {
+	env *Env
+}
+
+// node is a compiled expression.
+type node struct {
+	run func(ctx *Context) (any, error)
+	typ Type
+
+	// the Go type of the value, if known; for member access and indexing
+	gtyp reflect.Type
+
+	konst bool
+	value any
+}
+
+func constNode(v any) *node {
+	return &node{
+		run:   func(*Context) (any, error) { return v, nil },
+		typ:   typeOfValue(v),
+		gtyp:  reflect.TypeOf(v),
+		konst: true,
+		value: v,
+	}
+}
+
+// fold evaluates n if it is constant.
+func fold(n *node, konst bool) (*node, error) {
+	if !konst {
+		return n, nil
+	}
+	v, err := n.run(nil)
+	if err != nil {
+		return nil, err
+	}
+	return constNode(v), nil
+}
+
+func (c *compiler) compile(expr Expr) (*node, error) {
+	switch e := expr.(type) {
+	case *Const:
+		return constNode(e.Value), nil
+	case *Ident:
+		return c.ident(e)
+	case *Field:
+		return c.field(e)
+	case *Var:
+		name := e.Name
+		return &node{run: func(ctx *Context) (any, error) {
+			if ctx.GetVar != nil {
+				if v, ok := ctx.GetVar(name); ok {
+					return value(v), nil
+				}
+			}
+			return nil, fmt.Errorf("%w: %q", ErrVarNotDefined, name)
+		}}, nil
+	case *UnaryExpr:
+		return c.unary(e)
+	case *BinExpr:
+		return c.binary(e)
+	case *CondExpr:
+		return c.cond(e)
+	case *Selector:
+		return c.selector(e)
+	case *Index:
+		return c.index(e)
+	case *Call:
+		return c.call(e)
+	}
+	return nil, fmt.Errorf("expr.Compile(): can't compile %T", expr)
+}
+
+func (c *compiler) ident(e *Ident) (*node, error) {
+	if c.env.Ident != nil {
+		if v, ok := c.env.Ident(e.Name); ok {
+			return constNode(value(v)), nil
+		}
+	}
+	name := e.Name
+	return &node{run: func(ctx *Context) (any, error) {
+		if ctx.GetIdent != nil {
+			if v, ok := ctx.GetIdent(name); ok {
+				return value(v), nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %q", ErrIdentNotFound, name)
+	}}, nil
+}
+
+func (c *compiler) field(e *Field) (*node, error) {
+	n := &node{}
+	if c.env.Field != nil {
+		t, found := c.env.Field(e.Name)
+		if !found {
+			return nil, fmt.Errorf("%w: %q", ErrFieldNotFound, e.Name)
+		}
+		n.typ, n.gtyp = TypeOf(t), t
+	}
+	name := e.Name
+	n.run = func(ctx *Context) (any, error) {
+		if ctx.GetField != nil {
+			if v, ok := ctx.GetField(name); ok {
+				return value(v), nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %q", ErrFieldNotFound, name)
+	}
+	return n, nil
+}
+
+func (c *compiler) unary(e *UnaryExpr) (*node, error) {
+	x, err := c.compile(e.X)
+	if err != nil {
+		return nil, err
+	}
+	n := &node{}
+	switch e.Op {
+	case SUB:
+		if x.typ != TypeUnknown && !x.typ.isNumber() {
+			return nil, fmt.Errorf("%w: -%s", ErrType, x.typ)
+		}
+		n.typ = x.typ
+		n.run = func(ctx *Context) (any, error) {
+			v, err := x.run(ctx)
+			if err != nil {
+				return nil, err
+			}
+			switch num := v.(type) {
+			case int64:
+				if num == math.MinInt64 {
+					return nil, fmt.Errorf("%w: -(%d)", ErrOverflow, num)
+				}
+				return -num, nil
+			case uint64:
+				return Arith(SUB, int64(0), num)
+			case float64:
+				return -num, nil
+			}
+			return nil, fmt.Errorf("%w: -%s", ErrType, typeName(v))
+		}
+	case NOT:
+		n.typ = TypeBool
+		n.run = func(ctx *Context) (any, error) {
+			v, err := x.run(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return !Bool(v), nil
+		}
+	default:
+		return nil, fmt.Errorf("unaryexpr %s not supported", e.Op)
+	}
+	return fold(n, x.konst)
+}
+
+func (c *compiler) binary(e *BinExpr) (*node, error) {
+	x, err := c.compile(e.Lhs)
+	if err != nil {
+		return nil, err
+	}
+	y, err := c.compile(e.Rhs)
+	if err != nil {
+		return nil, err
+	}
+	op := e.Op
+	n := &node{}
+	switch op {
+	case LAND, LOR:
+		if x.konst {
+			// the constant operand decides or is dropped
+			if Bool(x.value) == (op == LOR) {
+				return constNode(op == LOR), nil
+			}
+			return c.boolOf(y)
+		}
+		n.typ = TypeBool
+		n.run = func(ctx *Context) (any, error) {
+			lhs, err := x.run(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if Bool(lhs) == (op == LOR) {
+				return op == LOR, nil
+			}
+			rhs, err := y.run(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return Bool(rhs), nil
+		}
+		return n, nil
+	case ADD, SUB, MUL, QUO, REM, AND, OR:
+		if n.typ, err = arithType(op, x.typ, y.typ); err != nil {
+			return nil, err
+		}
+		n.run = func(ctx *Context) (any, error) {
+			lhs, err := x.run(ctx)
+			if err != nil {
+				return nil, err
+			}
+			rhs, err := y.run(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return Arith(op, lhs, rhs)
+		}
+	default:
+		if err := compareType(op, x.typ, y.typ); err != nil {
+			return nil, err
+		}
+		n.typ = TypeBool
+		n.run = func(ctx *Context) (any, error) {
+			lhs, err := x.run(ctx)
+			if err != nil {
+				return nil, err
+			}
+			rhs, err := y.run(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return Compare(op, lhs, rhs)
+		}
+	}
+	return fold(n, x.konst && y.konst)
+}
+
+// boolOf converts the result of n with TypeBool.
+func (c *compiler) boolOf(n *node) (*node, error) {
+	if n.typ == TypeBool {
+		return n, nil
+	}
+	b := &node{typ: TypeBool, run: func(ctx *Context) (any, error) {
+		v, err := n.run(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return Bool(v), nil
+	}}
+	return fold(b, n.konst)
+}
+
+// arithType returns the type of x op y.
+func arithType(op Token, x, y Type) (Type, error) {
+	mismatch := func() (Type, error) {
+		return TypeUnknown, fmt.Errorf("%w: %s %s %s", ErrType, x, opText[op], y)
+	}
+	ok := func(t Type) bool {
+		switch t {
+		case TypeUnknown, TypeInt:
+			return true
+		case TypeFloat:
+			return op != AND && op != OR
+		case TypeString:
+			return op == ADD
+		}
+		return false
+	}
+	if !ok(x) || !ok(y) {
+		return mismatch()
+	}
+	switch {
+	case x == TypeUnknown || y == TypeUnknown:
+		if x == TypeString || y == TypeString {
+			return TypeString, nil
+		}
+		return TypeUnknown, nil
+	case x == TypeString || y == TypeString:
+		if x != y {
+			return mismatch()
+		}
+		return TypeString, nil
+	case x == TypeFloat || y == TypeFloat:
+		return TypeFloat, nil
+	}
+	return TypeInt, nil
+}
+
+// compareType checks that x and y can be compared with op.
+func compareType(op Token, x, y Type) error {
+	switch {
+	case x == TypeUnknown || y == TypeUnknown:
+		return nil
+	case x == TypeNil || y == TypeNil || x == TypeBool || y == TypeBool:
+		if op == EQL || op == NEQ {
+			return nil
+		}
+	case x.isNumber() && y.isNumber(), x == TypeString && y == TypeString:
+		return nil
+	}
+	return fmt.Errorf("%w: can't compare %s %s %s", ErrType, x, opText[op], y)
+}
+
+func (c *compiler) cond(e *CondExpr) (*node, error) {
+	cond, err := c.compile(e.Cond)
+	if err != nil {
+		return nil, err
+	}
+	then, err := c.compile(e.Then)
+	if err != nil {
+		return nil, err
+	}
+	els, err := c.compile(e.Else)
+	if err != nil {
+		return nil, err
+	}
+	if cond.konst {
+		if Bool(cond.value) {
+			return then, nil
+		}
+		return els, nil
+	}
+	n := &node{run: func(ctx *Context) (any, error) {
+		v, err := cond.run(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if Bool(v) {
+			return then.run(ctx)
+		}
+		return els.run(ctx)
+	}}
+	if then.typ == els.typ {
+		n.typ = then.typ
+	}
+	return n, nil
+}
+
+var membersType = reflect.TypeOf((*Members)(nil)).Elem()
+
+// elemType strips pointers from t; nil if the values of t are resolved
+// at run time.
+func elemType(t reflect.Type) reflect.Type {
+	if t == nil || t.Implements(membersType) {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		if t = t.Elem(); t.Implements(membersType) {
+			return nil
+		}
+	}
+	if t.Kind() == reflect.Interface {
+		return nil
+	}
+	return t
+}
+
+func (c *compiler) selector(e *Selector) (*node, error) {
+	x, err := c.compile(e.X)
+	if err != nil {
+		return nil, err
+	}
+	n := &node{run: func(ctx *Context) (any, error) {
+		v, err := x.run(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return member(v, e)
+	}}
+	if t := elemType(x.gtyp); t != nil {
+		switch t.Kind() {
+		case reflect.Struct:
+			f, found := t.FieldByName(e.Name)
+			if !found || !f.IsExported() {
+				return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, e)
+			}
+			n.gtyp = f.Type
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("%s: %s has no members", e, t)
+			}
+			n.gtyp = t.Elem()
+		default:
+			return nil, fmt.Errorf("%s: %s has no members", e, t)
+		}
+		n.typ = TypeOf(n.gtyp)
+	}
+	return fold(n, x.konst)
+}
+
+func (c *compiler) index(e *Index) (*node, error) {
+	x, err := c.compile(e.X)
+	if err != nil {
+		return nil, err
+	}
+	i, err := c.compile(e.Index)
+	if err != nil {
+		return nil, err
+	}
+	n := &node{run: func(ctx *Context) (any, error) {
+		v, err := x.run(ctx)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := i.run(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return index(v, idx, e)
+	}}
+	if t := elemType(x.gtyp); t != nil {
+		switch t.Kind() {
+		case reflect.Slice, reflect.Array, reflect.String:
+			if i.typ != TypeUnknown && i.typ != TypeInt {
+				return nil, fmt.Errorf("%s: invalid index type %s", e, i.typ)
+			}
+			if t.Kind() == reflect.String {
+				n.gtyp = reflect.TypeOf(byte(0))
+			} else {
+				n.gtyp = t.Elem()
+			}
+		case reflect.Map:
+			if k := TypeOf(t.Key()); i.typ != TypeUnknown && k != TypeUnknown && i.typ != k {
+				return nil, fmt.Errorf("%s: invalid index type %s", e, i.typ)
+			}
+			n.gtyp = t.Elem()
+		default:
+			return nil, fmt.Errorf("%s: can't index %s", e, t)
+		}
+		n.typ = TypeOf(n.gtyp)
+	}
+	return fold(n, x.konst && i.konst)
+}
+
+// builtinTypes are the result types of the builtins.
+var builtinTypes = map[string]Type{
+	"len":        TypeInt,
+	"lower":      TypeString,
+	"upper":      TypeString,
+	"startswith": TypeBool,
+	"endswith":   TypeBool,
+	"contains":   TypeBool,
+	"int8":       TypeInt,
+	"int16":      TypeInt,
+	"int32":      TypeInt,
+	"int64":      TypeInt,
+	"uint8":      TypeInt,
+	"uint16":     TypeInt,
+	"uint32":     TypeInt,
+	"uint64":     TypeInt,
+	"float32":    TypeFloat,
+	"float64":    TypeFloat,
+}
+
+func (c *compiler) call(e *Call) (*node, error) {
+	args := make([]*node, len(e.Args))
+	konst := true
+	for i, arg := range e.Args {
+		n, err := c.compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = n
+		konst = konst && n.konst
+	}
+
+	name := e.Name
+	fn, found := Func(nil), false
+	if c.env.Func != nil {
+		fn, found = c.env.Func(name)
+	}
+	n := &node{}
+	if !found {
+		if fn, found = builtins[name]; found {
+			n.typ = builtinTypes[name]
+		} else {
+			// resolved when evaluated
+			konst = false
+		}
+	}
+
+	n.run = func(ctx *Context) (any, error) {
+		f, ok := fn, found
+		if !ok {
+			if ctx.GetFunc != nil {
+				f, ok = ctx.GetFunc(name)
+			}
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrFuncNotFound, name)
+			}
+		}
+		vals := make([]any, len(args))
+		for i, arg := range args {
+			v, err := arg.run(ctx)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		v, err := f(vals...)
+		if err != nil {
+			return nil, fmt.Errorf("%s(): %w", name, err)
+		}
+		return v, nil
+	}
+	return fold(n, konst)
+}
@@ -0,0 +1,152 @@
+package expr_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio/expr"
+	"github.com/stretchr/testify/assert"
+)
+
+type compileHeader struct {
+	Count uint16
+	Tags  []string
+	Magic [4]byte
+	Names map[string]uint8
+}
+
+type compileStruct struct {
+	A      uint8
+	F      float32
+	Name   string
+	Header compileHeader
+}
+
+var compileEnv = &expr.Env{
+	Field: func(name string) (reflect.Type, bool) {
+		f, found := reflect.TypeOf(compileStruct{}).FieldByName(name)
+		return f.Type, found
+	},
+	Ident: func(name string) (any, bool) {
+		if name == "MAX" {
+			return 16, true
+		}
+		return nil, false
+	},
+}
+
+func TestCompile(t *testing.T) {
+	v := compileStruct{
+		A:    3,
+		F:    1.5,
+		Name: "RIFF",
+		Header: compileHeader{
+			Count: 2,
+			Tags:  []string{"a", "bc"},
+			Magic: [4]byte{'W', 'A', 'V', 'E'},
+		},
+	}
+	ctx := &expr.Context{
+		GetField: expr.GetFieldFn(reflect.ValueOf(v)),
+		GetVar: func(name string) (any, bool) {
+			return int64(10), name == "n"
+		},
+		GetIdent: compileEnv.Ident,
+	}
+	testdata := []struct {
+		In    string
+		Want  any
+		Type  expr.Type
+		Const bool
+	}{
+		{"1 + 2 * 3", int64(7), expr.TypeInt, true},
+		{"MAX * 2", int64(32), expr.TypeInt, true},
+		{"len('abc') > 2", true, expr.TypeBool, true},
+		{"false && %A", false, expr.TypeBool, true},
+		{"true && %A", true, expr.TypeBool, false},
+		{"1 > 2 ? %A : 2", int64(2), expr.TypeInt, true},
+		{"%A * 2", int64(6), expr.TypeInt, false},
+		{"%F * 2", float64(3), expr.TypeFloat, false},
+		{"%A + $n", int64(13), expr.TypeUnknown, false},
+		{"%Name == 'RIFF'", true, expr.TypeBool, false},
+		{`%Name + "x"`, "RIFFx", expr.TypeString, false},
+		{"%Header.Count + 1", int64(3), expr.TypeInt, false},
+		{"%Header.Tags[1]", "bc", expr.TypeString, false},
+		{"%Header.Magic == 'WAVE'", true, expr.TypeBool, false},
+		{"%A > 1 ? 'big' : 'small'", "big", expr.TypeString, false},
+		{"upper(%Name)", "RIFF", expr.TypeString, false},
+	}
+	for _, tst := range testdata {
+		ex, err := expr.Parse(tst.In)
+		if !assert.NoError(t, err, tst.In) {
+			continue
+		}
+		p, err := expr.Compile(ex, compileEnv)
+		if !assert.NoError(t, err, tst.In) {
+			continue
+		}
+		assert.Equal(t, tst.Type, p.Type, tst.In)
+		_, isConst := p.Const()
+		assert.Equal(t, tst.Const, isConst, tst.In)
+
+		have, err := p.Eval(ctx)
+		if assert.NoError(t, err, tst.In) {
+			assert.Equal(t, tst.Want, have, tst.In)
+		}
+	}
+}
+
+func TestCompile_errors(t *testing.T) {
+	testdata := []struct {
+		In  string
+		Err error
+		Msg string
+	}{
+		{"%Missing", expr.ErrFieldNotFound, `field not found: "Missing"`},
+		{"%Header.Missing", expr.ErrFieldNotFound, `field not found: %Header.Missing`},
+		{"%Name * 2", expr.ErrType, `invalid type: string * integer`},
+		{"%F & 1", expr.ErrType, `invalid type: float64 & integer`},
+		{`%A < "x"`, expr.ErrType, `invalid type: can't compare integer < string`},
+		{"-%Name", expr.ErrType, `invalid type: -string`},
+		{`%Header.Tags["x"]`, nil, `%Header.Tags["x"]: invalid index type string`},
+		{"%Header.Names[97]", nil, `%Header.Names[97]: invalid index type integer`},
+		{"%A.B", nil, `%A.B: uint8 has no members`},
+		{"1 / 0", expr.ErrDivZero, `division by zero`},
+		{"MAX * 9223372036854775807", expr.ErrOverflow, `integer overflow: 16 * 9223372036854775807`},
+		{"len(1)", nil, `len(): invalid argument type int64`},
+	}
+	for _, tst := range testdata {
+		ex, err := expr.Parse(tst.In)
+		if !assert.NoError(t, err, tst.In) {
+			continue
+		}
+		_, err = expr.Compile(ex, compileEnv)
+		if tst.Err != nil {
+			assert.ErrorIs(t, err, tst.Err, tst.In)
+		}
+		assert.EqualError(t, err, tst.Msg, tst.In)
+	}
+}
+
+func TestCompile_noEnv(t *testing.T) {
+	ex, err := expr.Parse("%Name * 2 + FOO")
+	if !assert.NoError(t, err) {
+		return
+	}
+	p, err := expr.Compile(ex, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, expr.TypeUnknown, p.Type)
+
+	ctx := &expr.Context{
+		GetField: func(name string) (any, bool) { return uint8(4), name == "Name" },
+		GetIdent: func(name string) (any, bool) { return 1, name == "FOO" },
+	}
+	v, err := p.Eval(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), v)
+
+	_, err = p.Eval(nil)
+	assert.ErrorIs(t, err, expr.ErrFieldNotFound)
+}
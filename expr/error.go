@@ -0,0 +1,83 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError is an error in the syntax of an expression, returned by
+// Parse and Scanner.Scan.
+type SyntaxError struct {
+	Offset   int      // byte offset of the error in the expression
+	Msg      string   // like `unexpected ")"`
+	Expected []string // the tokens expected at Offset, if known
+	Snippet  string   // the expression around Offset, set by Parse
+}
+
+// Reason describes the error without its position.
+func (err *SyntaxError) Reason() string {
+	switch len(err.Expected) {
+	case 0:
+		return err.Msg
+	case 1:
+		return fmt.Sprintf("%s, expected %s", err.Msg, err.Expected[0])
+	}
+	return fmt.Sprintf("%s, expected one of %s", err.Msg, strings.Join(err.Expected, ", "))
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("offset %d: %s", err.Offset, err.Reason())
+}
+
+// snippetLen is the number of bytes before and after the offset of a
+// SyntaxError in its snippet.
+const snippetLen = 24
+
+// snippet returns the text of src around off.
+func snippet(src string, off int) string {
+	start, end := max(off-snippetLen, 0), min(off+snippetLen, len(src))
+	if start > end {
+		return ""
+	}
+	return src[start:end]
+}
+
+// tokenDesc describes the unexpected token tok with the text txt.
+func tokenDesc(tok Token, txt string) string {
+	if tok == EOF || txt == "" {
+		return expected(tok)
+	}
+	return fmt.Sprintf("%q", txt)
+}
+
+// expected describes the token tok in errors.
+func expected(tok Token) string {
+	switch tok {
+	case EOF:
+		return "end of expression"
+	case IDENT:
+		return "identifier"
+	case INT, FLOAT, STRING, CHAR:
+		return "literal"
+	}
+	if s, found := opText[tok]; found {
+		return fmt.Sprintf("%q", s)
+	}
+	if s, found := punctText[tok]; found {
+		return fmt.Sprintf("%q", s)
+	}
+	return tok.String()
+}
+
+// punctText are the texts of the tokens which aren't operators.
+var punctText = map[Token]string{
+	DOL:    "$",
+	LPAREN: "(",
+	RPAREN: ")",
+	LBRACK: "[",
+	RBRACK: "]",
+	COMMA:  ",",
+	PERIOD: ".",
+	QUEST:  "?",
+	COLON:  ":",
+}
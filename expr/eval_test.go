@@ -32,20 +32,20 @@ func TestBool(t *testing.T) {
 		// int
 		{int(1), true},
 		{int(0), false},
-		{int(-10), false},
+		{int(-10), true},
 
 		{int8(1), true},
 		{int8(0), false},
-		{int8(-10), false},
+		{int8(-10), true},
 		{int16(1), true},
 		{int16(0), false},
-		{int16(-10), false},
+		{int16(-10), true},
 		{int32(1), true},
 		{int32(0), false},
-		{int32(-10), false},
+		{int32(-10), true},
 		{int64(1), true},
 		{int64(0), false},
-		{int64(-10), false},
+		{int64(-10), true},
 
 		{uint(1), true},
 		{uint(0), false},
@@ -61,16 +61,16 @@ func TestBool(t *testing.T) {
 		// float
 		{float32(1), true},
 		{float32(0), false},
-		{float32(-10.9), false},
+		{float32(-10.9), true},
 		{float32(math.NaN()), false},
 		{float32(math.Inf(1)), true},
-		{float32(math.Inf(-1)), false},
+		{float32(math.Inf(-1)), true},
 		{float64(1), true},
 		{float64(0), false},
-		{float64(-10.9), false},
+		{float64(-10.9), true},
 		{float64(math.NaN()), false},
 		{float64(math.Inf(1)), true},
-		{float64(math.Inf(-1)), false},
+		{float64(math.Inf(-1)), true},
 
 		{uintptr(12345), true},
 		{uintptr(0), false},
@@ -173,6 +173,41 @@ func TestEval(t *testing.T) {
 		{"false || false", false},
 		{"true || false", true},
 		{"false || true", true},
+
+		{"nil == nil", true},
+		{"1 != nil", true},
+		{"nil == false", false},
+
+		// strings
+		{`"RIFF"`, "RIFF"},
+		{`"a\tb"`, "a\tb"},
+		{"`a\\tb`", "a\\tb"},
+		{`'A'`, int64(65)},
+		{`'\n'`, int64(10)},
+		{`'RIFF'`, "RIFF"},
+		{`'\x89PNG'`, "\x89PNG"},
+		{`'\x89PNG' == "\x89PNG"`, true},
+		{`'é!'`, "é!"},
+		{`"abc" == 'abc'`, true},
+		{`"abc" < "abd"`, true},
+		{`"b" >= "abc"`, true},
+
+		// conditionals
+		{"true ? 8 : 4", int64(8)},
+		{"1 > 2 ? 8 : 4", int64(4)},
+		{`1 > 2 ? "a" : 1 < 2 ? "b" : "c"`, "b"},
+		{"if(true, 8, 4)", int64(8)},
+		{"if(1 == 2, 8, if(true, 2, 3))", int64(2)},
+		{"true ? 1 : $undefined", int64(1)},
+		{"if(false, %Missing, 2)", int64(2)},
+
+		// functions
+		{`len("RIFF")`, int64(4)},
+		{`lower("RIFF")`, "riff"},
+		{`upper('riff') == "RIFF"`, true},
+		{`startswith("RIFF", "RI")`, true},
+		{`endswith("RIFF", "RI")`, false},
+		{`contains("RIFF", "IF")`, true},
 	}
 
 	ctx := &expr.Context{}
@@ -187,3 +222,306 @@ func TestEval(t *testing.T) {
 		}
 	}
 }
+
+func TestEval_strings(t *testing.T) {
+	ctx := &expr.Context{
+		GetField: func(name string) (any, bool) {
+			switch name {
+			case "Magic":
+				return [4]byte{'R', 'I', 'F', 'F'}, true
+			case "Data":
+				return []byte("data"), true
+			}
+			return nil, false
+		},
+	}
+	testdata := []struct {
+		In   string
+		Want any
+	}{
+		{`%Magic == "RIFF"`, true},
+		{`%Magic != 'RIFF'`, false},
+		{`%Magic == %Data`, false},
+		{`%Magic < %Data`, true},
+		{`len(%Magic)`, int64(4)},
+		{`lower(%Magic) == "riff"`, true},
+		{`startswith(%Data, "da")`, true},
+	}
+	for _, tst := range testdata {
+		ex, err := expr.Parse(tst.In)
+		if assert.NoError(t, err, tst.In) {
+			have, err := expr.Eval(ctx, ex)
+			if assert.NoError(t, err, tst.In) {
+				assert.Equal(t, tst.Want, have, tst.In)
+			}
+		}
+	}
+}
+
+func TestEval_errors(t *testing.T) {
+	ctx := &expr.Context{
+		GetFunc: func(name string) (expr.Func, bool) {
+			return func(args ...any) (any, error) { return int64(len(args)), nil }, name == "nargs"
+		},
+	}
+	testdata := []struct {
+		In   string
+		Want error
+	}{
+		{`foo(1)`, expr.ErrFuncNotFound},
+		{`"1" == 1`, nil},
+		{`len(1)`, nil},
+		{`startswith("a")`, nil},
+	}
+	for _, tst := range testdata {
+		ex, err := expr.Parse(tst.In)
+		if !assert.NoError(t, err, tst.In) {
+			continue
+		}
+		_, err = expr.Eval(ctx, ex)
+		if assert.Error(t, err, tst.In) && tst.Want != nil {
+			assert.ErrorIs(t, err, tst.Want, tst.In)
+		}
+	}
+
+	v, err := expr.Eval(ctx, &expr.Call{Name: "nargs", Args: []expr.Expr{expr.NewConst(1), expr.NewConst(2)}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), v)
+	}
+
+	// the builtins take precedence over GetFunc
+	ctx.GetFunc = func(name string) (expr.Func, bool) {
+		return func(args ...any) (any, error) { return int64(-1), nil }, true
+	}
+	v, err = expr.Eval(ctx, &expr.Call{Name: "len", Args: []expr.Expr{&expr.Const{Value: "abc"}}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), v)
+	}
+}
+
+type members map[string]any
+
+func (m members) Member(name string) (any, bool) {
+	v, found := m[name]
+	return v, found
+}
+
+func TestEval_members(t *testing.T) {
+	type Size struct {
+		Len uint16
+	}
+	type Header struct {
+		Count int8
+		Sizes []Size
+		Names map[string]uint8
+		next  *Header
+	}
+	ctx := &expr.Context{
+		GetField: func(name string) (any, bool) {
+			switch name {
+			case "Header":
+				return &Header{Count: 2, Sizes: []Size{{1}, {20}}, Names: map[string]uint8{"a": 7}}, true
+			case "Map":
+				return map[string]any{"Inner": map[string]any{"X": uint32(5)}}, true
+			case "_parent":
+				return members{"Y": int64(3)}, true
+			}
+			return nil, false
+		},
+		GetVar: func(name string) (any, bool) { return 1, name == "i" },
+	}
+
+	testdata := []struct {
+		In   string
+		Want any
+	}{
+		{"%Header.Count", int64(2)},
+		{"%Header.Sizes[1].Len", int64(20)},
+		{"%Header.Sizes[$i].Len == 20", true},
+		{`%Header.Names["a"]`, int64(7)},
+		{"%Map.Inner.X", int64(5)},
+		{"%_parent.Y", int64(3)},
+		{`"abc"[1]`, int64('b')},
+	}
+	for _, tst := range testdata {
+		ex, err := expr.Parse(tst.In)
+		if !assert.NoError(t, err, tst.In) {
+			continue
+		}
+		have, err := expr.Eval(ctx, ex)
+		if assert.NoError(t, err, tst.In) {
+			assert.Equal(t, tst.Want, have, tst.In)
+		}
+	}
+
+	for _, in := range []string{
+		"%Header.Missing",
+		"%Header.next",
+		"%Header.Sizes[2]",
+		"%Header.Sizes[-1]",
+		`%Header.Sizes["a"]`,
+		`%Header.Names["b"]`,
+		"%Header.Names[97]",
+		"%Header.Count.X",
+		"%Header.Count[0]",
+		"%_parent.Z",
+	} {
+		ex, err := expr.Parse(in)
+		if assert.NoError(t, err, in) {
+			_, err := expr.Eval(ctx, ex)
+			assert.Error(t, err, in)
+		}
+	}
+}
+
+func TestEval_arith(t *testing.T) {
+	ctx := &expr.Context{
+		GetField: func(name string) (any, bool) {
+			switch name {
+			case "Big":
+				return uint64(math.MaxUint64), true
+			case "Small":
+				return uint8(3), true
+			case "F":
+				return float32(1.5), true
+			}
+			return nil, false
+		},
+	}
+	testdata := []struct {
+		In   string
+		Want any
+	}{
+		{"1 + 2 * 3", int64(7)},
+		{"(1 + 2) * 3", int64(9)},
+		{"10 - 2 - 3", int64(5)},
+		{"7 / 2", int64(3)},
+		{"7 % 4", int64(3)},
+		{"-7 % 4", int64(-3)},
+		{"6 & 3 | 8", int64(10)},
+		{"1 + 2 == 3", true},
+		{"1 < 2 == true", true},
+		{"%Small * 2", int64(6)},
+		{"%F * 2", float64(3)},
+		{"7 / 2.0", float64(3.5)},
+		{`"ab" + 'cd'`, "abcd"},
+		{"-(1 + 2)", int64(-3)},
+
+		// uint64
+		{"%Big", uint64(math.MaxUint64)},
+		{"%Big > 0", true},
+		{"%Big > -1", true},
+		{"%Big == 18446744073709551615", true},
+		{"9223372036854775807 + 1", uint64(1 << 63)},
+		{"%Big - %Big", int64(0)},
+		{"%Big / 2", int64(math.MaxInt64)},
+		{"%Big - 1", uint64(math.MaxUint64 - 1)},
+
+		// short-circuit
+		{"false && $undefined", false},
+		{"true || $undefined", true},
+		{"%Small && %Small > 2", true},
+		{"0 || 1", true},
+
+		// grouping
+		{"!(1 > 2)", true},
+		{"(true ? 1 : 2) + 1", int64(2)},
+	}
+	for _, tst := range testdata {
+		ex, err := expr.Parse(tst.In)
+		if !assert.NoError(t, err, tst.In) {
+			continue
+		}
+		have, err := expr.Eval(ctx, ex)
+		if assert.NoError(t, err, tst.In) {
+			assert.Equal(t, tst.Want, have, tst.In)
+		}
+	}
+}
+
+func TestEval_typeErrors(t *testing.T) {
+	ctx := &expr.Context{
+		GetField: func(name string) (any, bool) { return uint64(math.MaxUint64), name == "Big" },
+	}
+	testdata := []struct {
+		In   string
+		Want error
+		Msg  string
+	}{
+		{`1 + "a"`, expr.ErrType, `invalid type: integer + string`},
+		{`"a" < 1`, expr.ErrType, `invalid type: can't compare string < integer`},
+		{`true < false`, expr.ErrType, `invalid type: can't compare bool < bool`},
+		{`1.5 & 1`, expr.ErrType, `invalid type: float64 & integer`},
+		{`-"a"`, expr.ErrType, `invalid type: -string`},
+		{`1 / 0`, expr.ErrDivZero, `division by zero`},
+		{`-9223372036854775807 - 2`, expr.ErrOverflow, `integer overflow: -9223372036854775807 - 2`},
+		{`%Big * 2`, expr.ErrOverflow, `integer overflow: 18446744073709551615 * 2`},
+		{`-%Big`, expr.ErrOverflow, `integer overflow: 0 - 18446744073709551615`},
+	}
+	for _, tst := range testdata {
+		ex, err := expr.Parse(tst.In)
+		if !assert.NoError(t, err, tst.In) {
+			continue
+		}
+		_, err = expr.Eval(ctx, ex)
+		if assert.ErrorIs(t, err, tst.Want, tst.In) {
+			assert.EqualError(t, err, tst.Msg, tst.In)
+		}
+	}
+
+	_, err := expr.Int(uint64(math.MaxUint64))
+	assert.ErrorIs(t, err, expr.ErrOverflow)
+	_, err = expr.Int("1")
+	assert.ErrorIs(t, err, expr.ErrType)
+	n, err := expr.Int(uint16(3))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), n)
+	}
+}
+
+func TestEval_casts(t *testing.T) {
+	ctx := &expr.Context{
+		GetField: func(name string) (any, bool) { return uint64(math.MaxUint64), name == "Big" },
+	}
+	testdata := []struct {
+		In   string
+		Want any
+	}{
+		{"uint8(300)", int64(44)},
+		{"int8(255)", int64(-1)},
+		{"int8(-129)", int64(127)},
+		{"uint16(-1)", int64(math.MaxUint16)},
+		{"int16(0x12345)", int64(0x2345)},
+		{"uint32(4294967297)", int64(1)},
+		{"int32(%Big)", int64(-1)},
+		{"int64(%Big)", int64(-1)},
+		{"uint64(-1)", uint64(math.MaxUint64)},
+		{"uint64(%Big) == %Big", true},
+		{"uint8(-1.5)", int64(255)},
+		{"int16(2.9)", int64(2)},
+		{"uint8(1e20)", int64(0)},
+		{"uint16(%Big) * 2", int64(2 * math.MaxUint16)},
+		{"float32(0.1) == 0.1", false},
+		{"float32(1.5)", float64(1.5)},
+		{"float64(3) / 2", float64(1.5)},
+	}
+	for _, tst := range testdata {
+		ex, err := expr.Parse(tst.In)
+		if !assert.NoError(t, err, tst.In) {
+			continue
+		}
+		have, err := expr.Eval(ctx, ex)
+		if assert.NoError(t, err, tst.In) {
+			assert.Equal(t, tst.Want, have, tst.In)
+		}
+	}
+
+	for _, in := range []string{`uint8("a")`, "int8(true)", "float32(nil)", "uint8(1, 2)"} {
+		ex, err := expr.Parse(in)
+		if !assert.NoError(t, err, in) {
+			continue
+		}
+		_, err = expr.Eval(ctx, ex)
+		assert.Error(t, err, in)
+	}
+}
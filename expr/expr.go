@@ -1,11 +1,13 @@
 package expr
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type (
@@ -51,11 +53,33 @@ type (
 		Args []Expr
 	}
 
+	// CondExpr is the conditional expression Cond ? Then : Else. Calls
+	// of if(cond, then, else) are parsed as CondExpr.
+	CondExpr struct {
+		Cond Expr
+		Then Expr
+		Else Expr
+	}
+
+	// Selector is the member access X.Name.
+	Selector struct {
+		X    Expr
+		Name string
+	}
+
+	// Index is the index expression X[Index].
+	Index struct {
+		X     Expr
+		Index Expr
+	}
+
 	parser struct {
-		s Scanner
+		s   Scanner
+		src string
 
 		tok       Token
 		tokenText string
+		pos       int // offset of tok
 	}
 )
 
@@ -80,6 +104,9 @@ func (expr *UnaryExpr) String() string {
 }
 
 func (expr *Const) String() string {
+	if s, ok := expr.Value.(string); ok {
+		return strconv.Quote(s)
+	}
 	return fmt.Sprint(expr.Value)
 }
 
@@ -103,20 +130,41 @@ func (expr *Field) String() string {
 	return fmt.Sprintf("%%%s", expr.Name)
 }
 
+func (expr *CondExpr) String() string {
+	return fmt.Sprintf("(%s ? %s : %s)", expr.Cond, expr.Then, expr.Else)
+}
+
+func (expr *Selector) String() string {
+	return fmt.Sprintf("%s.%s", expr.X, expr.Name)
+}
+
+func (expr *Index) String() string {
+	return fmt.Sprintf("%s[%s]", expr.X, expr.Index)
+}
+
 func (p *parser) init(rd io.Reader) {
 	p.s.Init(rd)
 }
 
 func (p *parser) next() (tok Token) {
 	tok, err := p.s.Scan()
-	p.tokenText = p.s.TokenText()
 	if err != nil {
 		panic(err)
 	}
-	p.tok = tok
+	p.tok, p.tokenText, p.pos = tok, p.s.TokenText(), p.s.Position.Offset
 	return
 }
 
+// errorf aborts parsing with a SyntaxError at the offset off.
+func (p *parser) errorf(off int, expected []string, format string, args ...any) {
+	panic(&SyntaxError{Offset: off, Msg: fmt.Sprintf(format, args...), Expected: expected})
+}
+
+// unexpected aborts parsing at the current token.
+func (p *parser) unexpected(expected ...string) {
+	p.errorf(p.pos, expected, "unexpected %s", tokenDesc(p.tok, p.tokenText))
+}
+
 func (p *parser) accept(t Token) bool {
 	if p.tok == t {
 		p.next()
@@ -138,11 +186,12 @@ func (p *parser) expect(t Token) bool {
 	if p.accept(t) {
 		return true
 	}
-	panic(fmt.Errorf("unexpected symbol %s", p.tok))
+	p.unexpected(expected(t))
+	return false
 }
 
 func (p *parser) atom() (expr Expr) {
-	txt := p.tokenText
+	txt, pos := p.tokenText, p.pos
 	switch {
 	case p.accept(IDENT):
 		switch txt {
@@ -166,21 +215,75 @@ func (p *parser) atom() (expr Expr) {
 	case p.accept(INT):
 		v, err := strconv.ParseInt(txt, 0, 64)
 		if err != nil {
-			panic(err)
+			u, err2 := strconv.ParseUint(txt, 0, 64)
+			if err2 != nil {
+				p.errorf(pos, nil, "invalid integer %s: %v", txt, errors.Unwrap(err))
+			}
+			expr = &Const{Value: u}
+			break
 		}
 		expr = &Const{Value: v}
 	case p.accept(FLOAT):
 		v, err := strconv.ParseFloat(txt, 64)
 		if err != nil {
-			panic(err)
+			p.errorf(pos, nil, "invalid float %s: %v", txt, errors.Unwrap(err))
+		}
+		expr = &Const{Value: v}
+	case p.accept(STRING):
+		v, err := strconv.Unquote(txt)
+		if err != nil {
+			p.errorf(pos, nil, "invalid string %s: %v", txt, err)
+		}
+		expr = &Const{Value: v}
+	case p.accept(CHAR):
+		v, err := unquoteChar(txt)
+		if err != nil {
+			p.errorf(pos, nil, "invalid char %s: %v", txt, err)
 		}
 		expr = &Const{Value: v}
+	case p.accept(LPAREN): // (expr)
+		expr = p.expr()
+		p.expect(RPAREN)
 	default:
-		panic(fmt.Errorf("atom(): invalid token here: %s", p.tok))
+		p.unexpected("operand")
 	}
 	return
 }
 
+// unquoteChar returns the value of a single-quoted literal: an int64 for
+// a single character like 'A', a string for longer texts like 'RIFF'.
+// Escapes like \x89 are single bytes in strings.
+// Strings in single quotes are easier to write in struct tags.
+func unquoteChar(txt string) (any, error) {
+	var (
+		s     = txt[1 : len(txt)-1]
+		n     int
+		first rune
+		buf   []byte
+	)
+	for len(s) > 0 {
+		r, multibyte, tail, err := strconv.UnquoteChar(s, '\'')
+		if err != nil {
+			return nil, err
+		}
+		// \x and octal escapes are bytes, like in strconv.Unquote
+		if r < utf8.RuneSelf || !multibyte {
+			buf = append(buf, byte(r))
+		} else {
+			buf = utf8.AppendRune(buf, r)
+		}
+		if n == 0 {
+			first = r
+		}
+		n++
+		s = tail
+	}
+	if n == 1 {
+		return int64(first), nil
+	}
+	return string(buf), nil
+}
+
 func (p *parser) unary() Expr {
 	switch {
 	case p.accept(SUB):
@@ -194,35 +297,61 @@ func (p *parser) unary() Expr {
 			X:  p.unary(),
 		}
 	}
-	return p.atom()
+	return p.postfix()
 }
 
-func (p *parser) pred1() Expr {
-	expr := p.unary()
-	if p.accept(LPAREN) { // (
-		cexpr := &Call{
-			Name: expr.(*Ident).Name,
-		}
-		expr = cexpr
-		if p.accept(RPAREN) {
-			// no arguments
-		} else {
-			e := p.expr()
-			cexpr.Args = append(cexpr.Args, e)
-			for p.accept(COMMA) {
-				cexpr.Args = append(cexpr.Args, p.expr())
+// postfix parses calls, member access and index expressions.
+func (p *parser) postfix() Expr {
+	pos := p.pos
+	expr := p.atom()
+	for {
+		switch {
+		case p.tok == LPAREN: // (
+			ident, ok := expr.(*Ident)
+			if !ok {
+				p.errorf(p.pos, nil, "can't call %s", expr)
+			}
+			p.next()
+			cexpr := &Call{
+				Name: ident.Name,
+			}
+			expr = cexpr
+			if p.accept(RPAREN) {
+				// no arguments
+			} else {
+				e := p.expr()
+				cexpr.Args = append(cexpr.Args, e)
+				for p.accept(COMMA) {
+					cexpr.Args = append(cexpr.Args, p.expr())
+				}
+				p.expect(RPAREN) // )
+			}
+			if cexpr.Name == "if" {
+				if len(cexpr.Args) != 3 {
+					p.errorf(pos, nil, "if() needs 3 arguments, got %d", len(cexpr.Args))
+				}
+				expr = &CondExpr{Cond: cexpr.Args[0], Then: cexpr.Args[1], Else: cexpr.Args[2]}
 			}
-			p.expect(RPAREN) // )
+		case p.accept(PERIOD): // .Name
+			txt := p.tokenText
+			p.expect(IDENT)
+			expr = &Selector{X: expr, Name: txt}
+		case p.accept(LBRACK): // [index]
+			expr = &Index{X: expr, Index: p.expr()}
+			p.expect(RBRACK)
+		default:
+			return expr
 		}
 	}
-	return expr
 }
 
-func (p *parser) comp() Expr {
-	expr := p.pred1()
+// binary parses left-associative binary expressions of the operators ops
+// with operands parsed by next.
+func (p *parser) binary(next func() Expr, ops ...Token) Expr {
+	expr := next()
 	tok := p.tok
-	for p.acceptAny(EQL, NEQ, GTR, LSS, GEQ, LEQ) {
-		rhs := p.pred1()
+	for p.acceptAny(ops...) {
+		rhs := next()
 		expr = &BinExpr{
 			Op:  tok,
 			Lhs: expr,
@@ -233,25 +362,46 @@ func (p *parser) comp() Expr {
 	return expr
 }
 
+func (p *parser) mul() Expr {
+	return p.binary(p.unary, MUL, QUO, REM, AND)
+}
+
+func (p *parser) add() Expr {
+	return p.binary(p.mul, ADD, SUB, OR)
+}
+
+func (p *parser) comp() Expr {
+	return p.binary(p.add, EQL, NEQ, GTR, LSS, GEQ, LEQ)
+}
+
+// expr parses conditional expressions, which bind weakest.
 func (p *parser) expr() Expr {
-	expr := p.comp()
-	tok := p.tok
-	for p.acceptAny(LAND, LOR) {
-		rhs := p.comp()
-		expr = &BinExpr{
-			Op:  tok,
-			Lhs: expr,
-			Rhs: rhs,
-		}
-		tok = p.tok
+	cond := p.or()
+	if !p.accept(QUEST) {
+		return cond
 	}
-	return expr
+	then := p.expr()
+	p.expect(COLON)
+	return &CondExpr{Cond: cond, Then: then, Else: p.expr()}
+}
+
+func (p *parser) or() Expr {
+	return p.binary(p.and, LOR)
+}
+
+func (p *parser) and() Expr {
+	return p.binary(p.comp, LAND)
 }
 
 func (p *parser) parse() (expr Expr, err error) {
 	defer func() {
 		if e := recover(); e != nil {
-			err = e.(error)
+			serr, ok := e.(*SyntaxError)
+			if !ok {
+				panic(e)
+			}
+			serr.Snippet = snippet(p.src, serr.Offset)
+			expr, err = nil, serr
 		}
 	}()
 
@@ -262,7 +412,7 @@ func (p *parser) parse() (expr Expr, err error) {
 }
 
 func Parse(str string) (expr Expr, err error) {
-	var p parser
+	p := parser{src: str}
 	p.init(strings.NewReader(str))
 	expr, err = p.parse()
 	return
@@ -48,6 +48,18 @@ func TestParse(t *testing.T) {
 		{"1 > 0 && $foo != 12", false},
 		{"1 > 0 && %foo != 12", false},
 
+		{`%Magic == "RIFF"`, false},
+		{`%Header.Count[1].X > 0`, false},
+		{`-%A.B`, false},
+		{`%A.`, true},
+		{`%A[1`, true},
+		{`%A(1)`, true},
+		{`%A ? 1 : 2`, false},
+		{`%A ? 1`, true},
+		{`if(1, 2)`, true},
+		{`"RIFF`, true},
+		{`''`, false},
+
 		{"1 >>> 0", true},
 	}
 	for _, tst := range testdata {
@@ -107,6 +119,18 @@ func TestExprMarshal(t *testing.T) {
 		{"1 > 0 && $foo != 12", false},
 		{"1 > 0 && %foo != 12", false},
 
+		{`%Magic == "RIFF"`, false},
+		{`%Header.Count[1].X > 0`, false},
+		{`-%A.B`, false},
+		{`%A.`, true},
+		{`%A[1`, true},
+		{`%A(1)`, true},
+		{`%A ? 1 : 2`, false},
+		{`%A ? 1`, true},
+		{`if(1, 2)`, true},
+		{`"RIFF`, true},
+		{`''`, false},
+
 		{"1 >>> 0", true},
 	}
 
@@ -118,3 +142,72 @@ func TestExprMarshal(t *testing.T) {
 	}
 }
 */
+
+func TestParse_postfix(t *testing.T) {
+	e, err := expr.Parse("%_parent.Sizes[$i].Len")
+	if assert.NoError(t, err) {
+		assert.Equal(t, &expr.Selector{
+			X: &expr.Index{
+				X:     &expr.Selector{X: &expr.Field{Name: "_parent"}, Name: "Sizes"},
+				Index: &expr.Var{Name: "i"},
+			},
+			Name: "Len",
+		}, e)
+		assert.Equal(t, "%_parent.Sizes[$i].Len", e.String())
+	}
+}
+
+func TestParse_cond(t *testing.T) {
+	e, err := expr.Parse("%A ? 1 : %B ? 2 : 3")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "(%A ? 1 : (%B ? 2 : 3))", e.String())
+	}
+	e, err = expr.Parse("if(%A > 1, 1, 2)")
+	if assert.NoError(t, err) {
+		assert.Equal(t, &expr.CondExpr{
+			Cond: &expr.BinExpr{Op: expr.GTR, Lhs: &expr.Field{Name: "A"}, Rhs: expr.NewConst(1)},
+			Then: expr.NewConst(1),
+			Else: expr.NewConst(2),
+		}, e)
+	}
+}
+
+func TestParse_syntaxErrors(t *testing.T) {
+	testdata := []struct {
+		In     string
+		Offset int
+		Msg    string
+	}{
+		{"", 0, "unexpected end of expression, expected operand"},
+		{"%A &&", 5, "unexpected end of expression, expected operand"},
+		{"%A = 1", 3, `unexpected "=", expected "=="`},
+		{"1 2", 2, `unexpected "2", expected end of expression`},
+		{"(1 + 2", 6, `unexpected end of expression, expected ")"`},
+		{"%A[1", 4, `unexpected end of expression, expected "]"`},
+		{"%A.", 3, "unexpected end of expression, expected identifier"},
+		{"%A.B(1)", 4, "can't call %A.B"},
+		{"%A ? 1", 6, `unexpected end of expression, expected ":"`},
+		{"1 + if(1, 2)", 4, "if() needs 3 arguments, got 2"},
+		{`1 + "RIFF`, 4, "literal not terminated"},
+		{"%A # 1", 3, `invalid token "#"`},
+		{"99999999999999999999", 0, "invalid integer 99999999999999999999: value out of range"},
+	}
+	for _, tst := range testdata {
+		_, err := expr.Parse(tst.In)
+		var serr *expr.SyntaxError
+		if !assert.ErrorAs(t, err, &serr, tst.In) {
+			continue
+		}
+		assert.Equal(t, tst.Offset, serr.Offset, tst.In)
+		assert.Equal(t, tst.Msg, serr.Reason(), tst.In)
+		assert.Equal(t, tst.In, serr.Snippet, tst.In)
+	}
+
+	_, err := expr.Parse("%Header.Entries[0].Size + %Header.Entries[1] Size")
+	assert.EqualError(t, err, `offset 45: unexpected "Size", expected end of expression`)
+	var serr *expr.SyntaxError
+	if assert.ErrorAs(t, err, &serr) {
+		assert.Equal(t, []string{"end of expression"}, serr.Expected)
+		assert.Equal(t, "ze + %Header.Entries[1] Size", serr.Snippet)
+	}
+}
@@ -0,0 +1,154 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Precedence of the expressions, from the weakest binding conditional
+// expressions to postfix expressions and operands.
+const (
+	precCond = iota + 1
+	precOr
+	precAnd
+	precComp
+	precAdd
+	precMul
+	precUnary
+	precPostfix
+)
+
+// binaryPrec are the precedences of the binary operators, see parser.
+var binaryPrec = map[Token]int{
+	LOR:  precOr,
+	LAND: precAnd,
+	EQL:  precComp, NEQ: precComp, LSS: precComp, GTR: precComp, LEQ: precComp, GEQ: precComp,
+	ADD: precAdd, SUB: precAdd, OR: precAdd,
+	MUL: precMul, QUO: precMul, REM: precMul, AND: precMul,
+}
+
+// prec returns the precedence of e.
+func prec(e Expr) int {
+	switch e := e.(type) {
+	case *CondExpr:
+		return precCond
+	case *BinExpr:
+		return binaryPrec[e.Op]
+	case *UnaryExpr:
+		return precUnary
+	case *Const:
+		switch v := e.Value.(type) {
+		case int64:
+			if v < 0 {
+				return precUnary
+			}
+		case float64:
+			if math.Signbit(v) {
+				return precUnary
+			}
+		}
+	}
+	return precPostfix
+}
+
+// Format returns the canonical text of e, with parentheses only where
+// they are needed, unlike String which puts all operations in parentheses.
+// Parse parses the text to an expression which evaluates like e. It is
+// equal to e, except for infinite and NaN floats, which have no literals,
+// and negative constants, which are parsed as the negation of a positive
+// constant.
+func Format(e Expr) string {
+	var sb strings.Builder
+	format(&sb, e)
+	return sb.String()
+}
+
+// operand formats e in parentheses if it binds weaker than min.
+func operand(sb *strings.Builder, e Expr, min int) {
+	if prec(e) < min {
+		sb.WriteByte('(')
+		format(sb, e)
+		sb.WriteByte(')')
+		return
+	}
+	format(sb, e)
+}
+
+func format(sb *strings.Builder, e Expr) {
+	switch e := e.(type) {
+	case nil:
+		sb.WriteString("nil")
+	case *Const:
+		sb.WriteString(formatConst(e.Value))
+	case *Ident:
+		sb.WriteString(e.Name)
+	case *Field:
+		sb.WriteString("%" + e.Name)
+	case *Var:
+		sb.WriteString("$" + e.Name)
+	case *UnaryExpr:
+		sb.WriteString(opText[e.Op])
+		if _, ok := e.X.(*Const); ok {
+			// -(-1), not --1
+			operand(sb, e.X, precUnary+1)
+		} else {
+			operand(sb, e.X, precUnary)
+		}
+	case *BinExpr:
+		p := binaryPrec[e.Op]
+		operand(sb, e.Lhs, p)
+		sb.WriteString(" " + opText[e.Op] + " ")
+		// binary operators are left-associative
+		operand(sb, e.Rhs, p+1)
+	case *CondExpr:
+		operand(sb, e.Cond, precOr)
+		sb.WriteString(" ? ")
+		format(sb, e.Then)
+		sb.WriteString(" : ")
+		format(sb, e.Else)
+	case *Selector:
+		if _, ok := e.X.(*Const); ok {
+			operand(sb, e.X, precPostfix+1)
+		} else {
+			operand(sb, e.X, precPostfix)
+		}
+		sb.WriteString("." + e.Name)
+	case *Index:
+		operand(sb, e.X, precPostfix)
+		sb.WriteByte('[')
+		format(sb, e.Index)
+		sb.WriteByte(']')
+	case *Call:
+		sb.WriteString(e.Name + "(")
+		for i, arg := range e.Args {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			format(sb, arg)
+		}
+		sb.WriteByte(')')
+	default:
+		sb.WriteString(e.String())
+	}
+}
+
+// formatConst returns the literal of the constant value v.
+func formatConst(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	case []byte:
+		return strconv.Quote(string(v))
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0" // not an integer
+		}
+		return s
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,157 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// Func is a function that can be called in expressions. The arguments
+// are the evaluated argument expressions.
+type Func func(args ...any) (any, error)
+
+// builtins are the functions available in all expressions:
+//
+//	len(x)              length of a string, slice, array or map
+//	lower(s), upper(s)  s in lower or upper case
+//	startswith(s, p)    s starts with p
+//	endswith(s, p)      s ends with p
+//	contains(s, p)      s contains p
+//	int8(x) .. uint64(x) x converted to the integer type
+//	float32(x)          x converted to float32 and back to float64
+//	float64(x)          x converted to float64
+//
+// Strings may also be byte slices and arrays, like [4]byte FourCC codes.
+// Integer conversions wrap around like conversions in Go, uint8(300) is
+// 44 and int8(255) is -1; floats are truncated toward zero first.
+var builtins = map[string]Func{
+	"len": func(args ...any) (any, error) {
+		if err := wantArgs(args, 1); err != nil {
+			return nil, err
+		}
+		v := reflect.ValueOf(args[0])
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			return int64(v.Len()), nil
+		}
+		return nil, fmt.Errorf("invalid argument type %T", args[0])
+	},
+	"lower":      stringFunc(strings.ToLower),
+	"upper":      stringFunc(strings.ToUpper),
+	"startswith": stringPred(strings.HasPrefix),
+	"endswith":   stringPred(strings.HasSuffix),
+	"contains":   stringPred(strings.Contains),
+	"int8":       intCast(8, true),
+	"int16":      intCast(16, true),
+	"int32":      intCast(32, true),
+	"int64":      intCast(64, true),
+	"uint8":      intCast(8, false),
+	"uint16":     intCast(16, false),
+	"uint32":     intCast(32, false),
+	"uint64":     intCast(64, false),
+	"float32":    floatCast(32),
+	"float64":    floatCast(64),
+}
+
+func wantArgs(args []any, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("want %d arguments, got %d", n, len(args))
+	}
+	return nil
+}
+
+// stringArgs converts all args to strings.
+func stringArgs(args []any) ([]string, error) {
+	res := make([]string, len(args))
+	for i, arg := range args {
+		v := reflect.ValueOf(arg)
+		if !isString(v) {
+			return nil, fmt.Errorf("argument %d: want string, got %T", i+1, arg)
+		}
+		res[i] = stringOf(v)
+	}
+	return res, nil
+}
+
+func stringFunc(fn func(string) string) Func {
+	return func(args ...any) (any, error) {
+		if err := wantArgs(args, 1); err != nil {
+			return nil, err
+		}
+		s, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return fn(s[0]), nil
+	}
+}
+
+func stringPred(fn func(s, x string) bool) Func {
+	return func(args ...any) (any, error) {
+		if err := wantArgs(args, 2); err != nil {
+			return nil, err
+		}
+		s, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return fn(s[0], s[1]), nil
+	}
+}
+
+var maxUint64 = new(big.Int).SetUint64(math.MaxUint64)
+
+// intCast converts numbers to integers with the given number of bits.
+func intCast(bits uint, signed bool) Func {
+	shift := 64 - bits
+	return func(args ...any) (any, error) {
+		if err := wantArgs(args, 1); err != nil {
+			return nil, err
+		}
+		var u uint64 // the value modulo 2^64
+		switch v := value(args[0]).(type) {
+		case int64:
+			u = uint64(v)
+		case uint64:
+			u = v
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("can't convert %v to an integer", v)
+			}
+			n, _ := big.NewFloat(v).Int(nil)
+			u = n.And(n, maxUint64).Uint64()
+		default:
+			return nil, fmt.Errorf("%w: want number, got %s", ErrType, typeName(v))
+		}
+		if signed {
+			return int64(u<<shift) >> shift, nil
+		}
+		return uintValue(u << shift >> shift), nil
+	}
+}
+
+// floatCast converts numbers to floats with the given number of bits.
+func floatCast(bits int) Func {
+	return func(args ...any) (any, error) {
+		if err := wantArgs(args, 1); err != nil {
+			return nil, err
+		}
+		v := value(args[0])
+		if !isNumber(v) {
+			return nil, fmt.Errorf("%w: want number, got %s", ErrType, typeName(v))
+		}
+		f := toFloat(v)
+		if bits == 32 {
+			f = float64(float32(f))
+		}
+		return f, nil
+	}
+}
+
+// Builtin returns the builtin function name.
+func Builtin(name string) (Func, bool) {
+	fn, found := builtins[name]
+	return fn, found
+}
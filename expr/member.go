@@ -0,0 +1,87 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// indirect strips pointers and interfaces from v.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// member returns the member e.Name of x, a struct, a map with string keys
+// or a value implementing Members.
+func member(x any, e *Selector) (any, error) {
+	if m, ok := x.(Members); ok {
+		if v, found := m.Member(e.Name); found {
+			return v, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, e)
+	}
+
+	v := indirect(reflect.ValueOf(x))
+	switch v.Kind() {
+	case reflect.Struct:
+		if f, found := v.Type().FieldByName(e.Name); found && f.IsExported() {
+			return Value(v.FieldByIndex(f.Index)), nil
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			if m := v.MapIndex(reflect.ValueOf(e.Name).Convert(v.Type().Key())); m.IsValid() {
+				return Value(indirect(m)), nil
+			}
+		}
+	case reflect.Invalid:
+		return nil, fmt.Errorf("%s: %s is nil", e, e.X)
+	default:
+		return nil, fmt.Errorf("%s: %s has no members", e, v.Type())
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, e)
+}
+
+// index returns the element i of x, a slice, array, string or map.
+func index(x, i any, e *Index) (any, error) {
+	v := indirect(reflect.ValueOf(x))
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.String:
+		n, ok := Value(reflect.ValueOf(i)).(int64)
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid index type %T", e, i)
+		}
+		if n < 0 || n >= int64(v.Len()) {
+			return nil, fmt.Errorf("%s: index %d out of range [0:%d]", e, n, v.Len())
+		}
+		return Value(v.Index(int(n))), nil
+	case reflect.Map:
+		k, key := reflect.ValueOf(i), v.Type().Key()
+		if !k.IsValid() || !isKeyType(k.Type(), key) {
+			return nil, fmt.Errorf("%s: invalid key type %T", e, i)
+		}
+		m := v.MapIndex(k.Convert(key))
+		if !m.IsValid() {
+			return nil, fmt.Errorf("%s: key %v not found", e, i)
+		}
+		return Value(indirect(m)), nil
+	case reflect.Invalid:
+		return nil, fmt.Errorf("%s: %s is nil", e, e.X)
+	default:
+		return nil, fmt.Errorf("%s: can't index %s", e, v.Type())
+	}
+}
+
+// isKeyType reports whether values of type t can be converted to map keys
+// of type key. Their static types must match: integers are not converted
+// to strings.
+func isKeyType(t, key reflect.Type) bool {
+	if kt := TypeOf(key); kt != TypeUnknown {
+		return TypeOf(t) == kt && t.ConvertibleTo(key)
+	}
+	return t == key
+}
@@ -42,15 +42,22 @@ const (
 	NOT // !
 
 	LPAREN // (
-	//LBRACK // [
 	//LBRACE // {
 	COMMA // ,
 
 	RPAREN // )
-	//	RBRACK // ]
 	//	RBRACE // }
 
 	EOF
+
+	// tokens added later, after EOF to keep the values of the others
+	STRING // "..." or `...`
+	CHAR   // '...'
+	LBRACK // [
+	RBRACK // ]
+	PERIOD // .
+	QUEST  // ?
+	COLON  // :
 )
 
 type (
@@ -61,6 +68,13 @@ var opText = map[Token]string{
 	// unary
 	NOT: "!",
 	// binary
+	ADD:  "+",
+	SUB:  "-",
+	MUL:  "*",
+	QUO:  "/",
+	REM:  "%",
+	AND:  "&",
+	OR:   "|",
 	LSS:  "<",
 	GTR:  ">",
 	EQL:  "==",
@@ -69,22 +83,36 @@ var opText = map[Token]string{
 	GEQ:  ">=",
 	LAND: "&&",
 	LOR:  "||",
-	// AND:  "&",
-	// OR:   "|",
 }
 
 type Scanner struct {
 	scanner.Scanner
+
+	err *SyntaxError // the first error reported by the scanner
 }
 
 func (s *Scanner) Init(rd io.Reader) {
 	s.Scanner.Init(rd)
 	s.Scanner.Whitespace = scanner.GoWhitespace
 	s.Scanner.Mode = scanner.GoTokens
+	s.Scanner.Error = func(sc *scanner.Scanner, msg string) {
+		// single-quoted literals may contain more than one character,
+		// see Parse
+		if msg != "invalid char literal" && s.err == nil {
+			pos := sc.Position
+			if !pos.IsValid() {
+				pos = sc.Pos()
+			}
+			s.err = &SyntaxError{Offset: pos.Offset, Msg: msg}
+		}
+	}
 }
 
 func (s *Scanner) Scan() (tok Token, err error) {
 	c := s.Scanner.Scan()
+	if s.err != nil {
+		return INVALID, s.err
+	}
 	if c == scanner.EOF {
 		tok = EOF
 		return
@@ -105,22 +133,41 @@ func (s *Scanner) Scan() (tok Token, err error) {
 		tok = FLOAT
 	case scanner.Int:
 		tok = INT
+	case scanner.String, scanner.RawString:
+		tok = STRING
+	case scanner.Char:
+		tok = CHAR
 	case ',':
 		tok = COMMA
 	case '(':
 		tok = LPAREN
 	case ')':
 		tok = RPAREN
+	case '[':
+		tok = LBRACK
+	case ']':
+		tok = RBRACK
+	case '.':
+		tok = PERIOD
+	case '?':
+		tok = QUEST
+	case ':':
+		tok = COLON
 	case '$':
 		tok = DOL
+	case '+':
+		tok = ADD
 	case '-':
 		tok = SUB
+	case '*':
+		tok = MUL
+	case '/':
+		tok = QUO
 	case '%':
 		tok = REM
 	case '=':
-		c := s.Next()
-		if c != '=' {
-			panic("need = here!") // TODO: improve
+		if !acceptRune('=') {
+			return INVALID, &SyntaxError{Offset: s.Position.Offset, Msg: `unexpected "="`, Expected: []string{`"=="`}}
 		}
 		tok = EQL
 	case '>':
@@ -149,7 +196,7 @@ func (s *Scanner) Scan() (tok Token, err error) {
 			tok = NEQ
 		}
 	default:
-		return INVALID, fmt.Errorf("invalid token %s", scanner.TokenString(c))
+		return INVALID, &SyntaxError{Offset: s.Position.Offset, Msg: fmt.Sprintf("invalid token %s", scanner.TokenString(c))}
 	}
 	return
 }
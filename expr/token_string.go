@@ -34,11 +34,18 @@ func _() {
 	_ = x[COMMA-23]
 	_ = x[RPAREN-24]
 	_ = x[EOF-25]
+	_ = x[STRING-26]
+	_ = x[CHAR-27]
+	_ = x[LBRACK-28]
+	_ = x[RBRACK-29]
+	_ = x[PERIOD-30]
+	_ = x[QUEST-31]
+	_ = x[COLON-32]
 }
 
-const _Token_name = "INVALIDIDENTINTFLOATFIELDADDSUBMULQUOREMDOLLSSGTREQLNEQLEQGEQLANDLORANDORNOTLPARENCOMMARPARENEOF"
+const _Token_name = "INVALIDIDENTINTFLOATFIELDADDSUBMULQUOREMDOLLSSGTREQLNEQLEQGEQLANDLORANDORNOTLPARENCOMMARPARENEOFSTRINGCHARLBRACKRBRACKPERIODQUESTCOLON"
 
-var _Token_index = [...]uint8{0, 7, 12, 15, 20, 25, 28, 31, 34, 37, 40, 43, 46, 49, 52, 55, 58, 61, 65, 68, 71, 73, 76, 82, 87, 93, 96}
+var _Token_index = [...]uint8{0, 7, 12, 15, 20, 25, 28, 31, 34, 37, 40, 43, 46, 49, 52, 55, 58, 61, 65, 68, 71, 73, 76, 82, 87, 93, 96, 102, 106, 112, 118, 124, 129, 134}
 
 func (i Token) String() string {
 	if i < 0 || i >= Token(len(_Token_index)-1) {
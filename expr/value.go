@@ -0,0 +1,383 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+
+	"golang.org/x/exp/constraints"
+)
+
+// Values of expressions have one of these types:
+//
+//	nil
+//	int64     all integers; uint64 only for values above math.MaxInt64
+//	uint64
+//	float64
+//	bool
+//	string
+//	[]byte or [N]byte, compared like strings
+//
+// and structs, maps and slices for member access and indexing. Values of
+// fields and variables are converted with Value.
+
+var (
+	ErrType     = errors.New("invalid type")
+	ErrOverflow = errors.New("integer overflow")
+	ErrDivZero  = errors.New("division by zero")
+)
+
+// Value converts v to the value types of expressions.
+func Value(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintValue(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		return v.String()
+	}
+	if !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// value converts v to the value types of expressions.
+func value(v any) any {
+	switch v.(type) {
+	case nil, int64, uint64, float64, bool, string, []byte:
+		return v
+	}
+	return Value(reflect.ValueOf(v))
+}
+
+func uintValue(u uint64) any {
+	if u <= math.MaxInt64 {
+		return int64(u)
+	}
+	return u
+}
+
+// typeName returns the name of the type of the value v in errors.
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case int64, uint64:
+		return "integer"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// Int converts the integer v to an int64.
+func Int(v any) (int64, error) {
+	switch n := value(v).(type) {
+	case int64:
+		return n, nil
+	case uint64:
+		return 0, fmt.Errorf("%w: %d does not fit in int64", ErrOverflow, n)
+	default:
+		return 0, fmt.Errorf("%w: want integer, got %s", ErrType, typeName(v))
+	}
+}
+
+// Bool reports whether val is true: non-zero numbers, non-empty strings
+// and slices, and non-nil pointers are true. NaN is false.
+func Bool(val any) bool {
+	v, ok := val.(reflect.Value)
+	if !ok {
+		v = reflect.ValueOf(val)
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return false
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() != 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr:
+		return v.Uint() != 0
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		return f != 0 && !math.IsNaN(f)
+	case reflect.String:
+		return v.Len() > 0
+	case reflect.Slice:
+		return v.Len() != 0
+	case reflect.Ptr:
+		return !v.IsNil()
+	}
+
+	return !v.IsZero()
+}
+
+// isString reports whether v is a string or a byte slice or array.
+func isString(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return true
+	case reflect.Slice, reflect.Array:
+		return v.Type().Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// stringOf returns the content of a string or a byte slice or array.
+func stringOf(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Slice:
+		return string(v.Bytes())
+	default:
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return string(b)
+	}
+}
+
+// asString returns the content of the string or bytes v.
+func asString(v any) (string, bool) {
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	rv := reflect.ValueOf(v)
+	if !isString(rv) {
+		return "", false
+	}
+	return stringOf(rv), true
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case int64, uint64, float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	}
+	return v.(float64)
+}
+
+func toBig(v any) *big.Int {
+	switch n := v.(type) {
+	case int64:
+		return big.NewInt(n)
+	default:
+		return new(big.Int).SetUint64(n.(uint64))
+	}
+}
+
+func cmp[E constraints.Ordered](op Token, x, y E) (res bool, err error) {
+	switch op {
+	case LSS:
+		res = x < y
+	case GTR:
+		res = x > y
+	case EQL:
+		res = x == y
+	case NEQ:
+		res = x != y
+	case LEQ:
+		res = x <= y
+	case GEQ:
+		res = x >= y
+	default:
+		return false, fmt.Errorf("invalid op %s", op)
+	}
+	return
+}
+
+// Compare compares lhs and rhs with the comparison operator op. Numbers
+// of different types are compared by value, strings and byte slices and
+// arrays by content. Bools can be compared with other values converted
+// with Bool, nil only for equality.
+func Compare(op Token, lhs, rhs any) (res bool, err error) {
+	x, y := value(lhs), value(rhs)
+	if rv, ok := lhs.(reflect.Value); ok {
+		x = Value(rv)
+	}
+	if rv, ok := rhs.(reflect.Value); ok {
+		y = Value(rv)
+	}
+	mismatch := func() (bool, error) {
+		return false, fmt.Errorf("%w: can't compare %s %s %s", ErrType, typeName(x), opText[op], typeName(y))
+	}
+
+	_, xb := x.(bool)
+	_, yb := y.(bool)
+	switch {
+	case x == nil || y == nil:
+		switch op {
+		case EQL:
+			return (x == nil) == (y == nil), nil
+		case NEQ:
+			return (x == nil) != (y == nil), nil
+		}
+		return mismatch()
+	case xb || yb:
+		a, b := Bool(x), Bool(y)
+		switch op {
+		case EQL:
+			return a == b, nil
+		case NEQ:
+			return a != b, nil
+		}
+		return mismatch()
+	case isNumber(x) && isNumber(y):
+		_, xf := x.(float64)
+		_, yf := y.(float64)
+		xi, xok := x.(int64)
+		yi, yok := y.(int64)
+		switch {
+		case xf || yf:
+			return cmp(op, toFloat(x), toFloat(y))
+		case xok && yok:
+			return cmp(op, xi, yi)
+		default:
+			return cmp(op, toBig(x).Cmp(toBig(y)), 0)
+		}
+	}
+	if a, ok := asString(x); ok {
+		if b, ok := asString(y); ok {
+			return cmp(op, a, b)
+		}
+	}
+	return mismatch()
+}
+
+// Arith applies the arithmetic operator op (+, -, *, /, %, &, |) to lhs and
+// rhs. Integer operations fail on overflow, + also concatenates strings.
+func Arith(op Token, lhs, rhs any) (any, error) {
+	x, y := value(lhs), value(rhs)
+	mismatch := func() (any, error) {
+		return nil, fmt.Errorf("%w: %s %s %s", ErrType, typeName(x), opText[op], typeName(y))
+	}
+
+	if !isNumber(x) || !isNumber(y) {
+		if op != ADD {
+			return mismatch()
+		}
+		a, ok := asString(x)
+		b, ok2 := asString(y)
+		if !ok || !ok2 {
+			return mismatch()
+		}
+		return a + b, nil
+	}
+
+	_, xf := x.(float64)
+	_, yf := y.(float64)
+	if xf || yf {
+		a, b := toFloat(x), toFloat(y)
+		switch op {
+		case ADD:
+			return a + b, nil
+		case SUB:
+			return a - b, nil
+		case MUL:
+			return a * b, nil
+		case QUO:
+			return a / b, nil
+		case REM:
+			return math.Mod(a, b), nil
+		}
+		return mismatch()
+	}
+
+	if a, ok := x.(int64); ok {
+		if b, ok := y.(int64); ok {
+			if r, ok := intArith(op, a, b); ok {
+				return r, nil
+			}
+		}
+	}
+
+	// uint64 operands or int64 overflow
+	a, b, r := toBig(x), toBig(y), new(big.Int)
+	switch op {
+	case ADD:
+		r.Add(a, b)
+	case SUB:
+		r.Sub(a, b)
+	case MUL:
+		r.Mul(a, b)
+	case QUO, REM:
+		if b.Sign() == 0 {
+			return nil, ErrDivZero
+		}
+		if op == QUO {
+			r.Quo(a, b)
+		} else {
+			r.Rem(a, b)
+		}
+	case AND:
+		r.And(a, b)
+	case OR:
+		r.Or(a, b)
+	default:
+		return mismatch()
+	}
+	switch {
+	case r.IsInt64():
+		return r.Int64(), nil
+	case r.IsUint64():
+		return r.Uint64(), nil
+	}
+	return nil, fmt.Errorf("%w: %s %s %s", ErrOverflow, a, opText[op], b)
+}
+
+// intArith applies op to a and b; ok is false on overflow or division by
+// zero.
+func intArith(op Token, a, b int64) (r int64, ok bool) {
+	switch op {
+	case ADD:
+		r = a + b
+		return r, (r > a) == (b > 0)
+	case SUB:
+		r = a - b
+		return r, (r < a) == (b > 0)
+	case MUL:
+		if a == 0 || b == 0 {
+			return 0, true
+		}
+		r = a * b
+		return r, r/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64)
+	case QUO:
+		if b == 0 || a == math.MinInt64 && b == -1 {
+			return 0, false
+		}
+		return a / b, true
+	case REM:
+		if b == 0 {
+			return 0, false
+		}
+		if b == -1 {
+			return 0, true
+		}
+		return a % b, true
+	case AND:
+		return a & b, true
+	case OR:
+		return a | b, true
+	}
+	return 0, false
+}
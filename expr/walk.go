@@ -0,0 +1,156 @@
+package expr
+
+// A Visitor's Visit method is called by Walk for each expression. If the
+// result w is not nil, Walk visits the children of e with w, followed by
+// a call of w.Visit(nil).
+type Visitor interface {
+	Visit(e Expr) (w Visitor)
+}
+
+// Walk traverses the expression e in depth-first order, see Visitor.
+// Nil expressions are ignored.
+func Walk(v Visitor, e Expr) {
+	if e == nil {
+		return
+	}
+	if v = v.Visit(e); v == nil {
+		return
+	}
+	switch e := e.(type) {
+	case *UnaryExpr:
+		Walk(v, e.X)
+	case *BinExpr:
+		Walk(v, e.Lhs)
+		Walk(v, e.Rhs)
+	case *CondExpr:
+		Walk(v, e.Cond)
+		Walk(v, e.Then)
+		Walk(v, e.Else)
+	case *Selector:
+		Walk(v, e.X)
+	case *Index:
+		Walk(v, e.X)
+		Walk(v, e.Index)
+	case *Call:
+		for _, arg := range e.Args {
+			Walk(v, arg)
+		}
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Expr) bool
+
+func (fn inspector) Visit(e Expr) Visitor {
+	if fn(e) {
+		return fn
+	}
+	return nil
+}
+
+// Inspect traverses e in depth-first order: it calls fn(e), and if the
+// result is true, Inspect visits the children of e, followed by fn(nil).
+func Inspect(e Expr, fn func(Expr) bool) {
+	Walk(inspector(fn), e)
+}
+
+// Rewrite replaces the expressions in e bottom-up with the result of fn,
+// which returns its argument to keep it. The expressions of e are not
+// modified, expressions whose children are replaced are copied.
+func Rewrite(e Expr, fn func(Expr) Expr) Expr {
+	if e == nil {
+		return nil
+	}
+	switch x := e.(type) {
+	case *UnaryExpr:
+		if r := Rewrite(x.X, fn); r != x.X {
+			e = &UnaryExpr{Op: x.Op, X: r}
+		}
+	case *BinExpr:
+		lhs, rhs := Rewrite(x.Lhs, fn), Rewrite(x.Rhs, fn)
+		if lhs != x.Lhs || rhs != x.Rhs {
+			e = &BinExpr{Op: x.Op, Lhs: lhs, Rhs: rhs}
+		}
+	case *CondExpr:
+		cond, then, els := Rewrite(x.Cond, fn), Rewrite(x.Then, fn), Rewrite(x.Else, fn)
+		if cond != x.Cond || then != x.Then || els != x.Else {
+			e = &CondExpr{Cond: cond, Then: then, Else: els}
+		}
+	case *Selector:
+		if r := Rewrite(x.X, fn); r != x.X {
+			e = &Selector{X: r, Name: x.Name}
+		}
+	case *Index:
+		v, i := Rewrite(x.X, fn), Rewrite(x.Index, fn)
+		if v != x.X || i != x.Index {
+			e = &Index{X: v, Index: i}
+		}
+	case *Call:
+		var args []Expr
+		for i, arg := range x.Args {
+			r := Rewrite(arg, fn)
+			if r != arg && args == nil {
+				args = append(make([]Expr, 0, len(x.Args)), x.Args[:i]...)
+			}
+			if args != nil {
+				args = append(args, r)
+			}
+		}
+		if args != nil {
+			e = &Call{Name: x.Name, Args: args}
+		}
+	}
+	return fn(e)
+}
+
+// names returns the names of the expressions in e for which name returns
+// true, in the order of their first appearance.
+func names(e Expr, name func(Expr) (string, bool)) []string {
+	var (
+		res  []string
+		seen = make(map[string]bool)
+	)
+	Inspect(e, func(e Expr) bool {
+		if n, ok := name(e); ok && !seen[n] {
+			seen[n] = true
+			res = append(res, n)
+		}
+		return true
+	})
+	return res
+}
+
+// Fields returns the names of the fields referenced in e, like A for
+// %A and %A.B.
+func Fields(e Expr) []string {
+	return names(e, func(e Expr) (string, bool) {
+		f, ok := e.(*Field)
+		if !ok {
+			return "", false
+		}
+		return f.Name, true
+	})
+}
+
+// Vars returns the names of the variables referenced in e.
+func Vars(e Expr) []string {
+	return names(e, func(e Expr) (string, bool) {
+		v, ok := e.(*Var)
+		if !ok {
+			return "", false
+		}
+		return v.Name, true
+	})
+}
+
+// Idents returns the identifiers referenced in e, not including the
+// names of functions.
+func Idents(e Expr) []string {
+	return names(e, func(e Expr) (string, bool) {
+		id, ok := e.(*Ident)
+		if !ok {
+			return "", false
+		}
+		return id.Name, true
+	})
+}
@@ -0,0 +1,138 @@
+package expr_test
+
+import (
+	"testing"
+
+	"github.com/KlemensWinter/go-binio/expr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspect(t *testing.T) {
+	e, err := expr.Parse("%A > 1 ? len(%B.C[$i]) : -X")
+	if !assert.NoError(t, err) {
+		return
+	}
+	var have []string
+	expr.Inspect(e, func(e expr.Expr) bool {
+		if e == nil {
+			have = append(have, "end")
+			return false
+		}
+		have = append(have, e.String())
+		// don't descend into calls
+		_, isCall := e.(*expr.Call)
+		return !isCall
+	})
+	assert.Equal(t, []string{
+		"((%A > 1) ? len(%B.C[$i]) : (-X))",
+		"(%A > 1)", "%A", "end", "1", "end", "end",
+		"len(%B.C[$i])",
+		"(-X)", "X", "end", "end",
+		"end",
+	}, have)
+
+	// nil expressions are ignored
+	expr.Inspect(nil, func(expr.Expr) bool {
+		t.Error("called for nil")
+		return true
+	})
+}
+
+func TestRefs(t *testing.T) {
+	e, err := expr.Parse("%A + %B.C[$i] * $n > MAX || %A == $i && len(%_parent.D) > MIN")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"A", "B", "_parent"}, expr.Fields(e))
+	assert.Equal(t, []string{"i", "n"}, expr.Vars(e))
+	assert.Equal(t, []string{"MAX", "MIN"}, expr.Idents(e))
+
+	assert.Nil(t, expr.Fields(expr.NewConst(1)))
+}
+
+func TestRewrite(t *testing.T) {
+	e, err := expr.Parse("%A * 2 + max(%A, $n)")
+	if !assert.NoError(t, err) {
+		return
+	}
+	// rename %A to %Count
+	have := expr.Rewrite(e, func(e expr.Expr) expr.Expr {
+		if f, ok := e.(*expr.Field); ok && f.Name == "A" {
+			return &expr.Field{Name: "Count"}
+		}
+		return e
+	})
+	assert.Equal(t, "%Count * 2 + max(%Count, $n)", expr.Format(have))
+	// e is unchanged
+	assert.Equal(t, "%A * 2 + max(%A, $n)", expr.Format(e))
+
+	// unchanged expressions are kept
+	same := expr.Rewrite(e, func(e expr.Expr) expr.Expr { return e })
+	assert.Same(t, e, same)
+}
+
+func TestFormat(t *testing.T) {
+	testdata := []struct {
+		In, Want string
+	}{
+		{"1 + 2 * 3", "1 + 2 * 3"},
+		{"(1 + 2) * 3", "(1 + 2) * 3"},
+		{"1 - (2 - 3)", "1 - (2 - 3)"},
+		{"(1 - 2) - 3", "1 - 2 - 3"},
+		{"%A&1|2", "%A & 1 | 2"},
+		{"%A & (1 | 2)", "%A & (1 | 2)"},
+		{"!(%A > 1) && ($b || %C)", "!(%A > 1) && ($b || %C)"},
+		{"-(-%A)", "--%A"},
+		{"-(%A + 1)", "-(%A + 1)"},
+		{"%A ? 1 : %B ? 2 : 3", "%A ? 1 : %B ? 2 : 3"},
+		{"(%A ? 1 : 2) ? 3 : 4", "(%A ? 1 : 2) ? 3 : 4"},
+		{"if(%A, %B ? 1 : 2, 3) + 1", "(%A ? %B ? 1 : 2 : 3) + 1"},
+		{"%_parent.Sizes[$_index + 1].Len", "%_parent.Sizes[$_index + 1].Len"},
+		{"(%A + %B).X", "(%A + %B).X"},
+		{`lower(%Name)=="riff"`, `lower(%Name) == "riff"`},
+		{`'RIFF' == 'R'`, `"RIFF" == 82`},
+		{"1.0 + 2.5e10", "1.0 + 2.5e+10"},
+		{"18446744073709551615", "18446744073709551615"},
+		{"nil != true", "nil != true"},
+	}
+	for _, tst := range testdata {
+		e, err := expr.Parse(tst.In)
+		if !assert.NoError(t, err, tst.In) {
+			continue
+		}
+		have := expr.Format(e)
+		assert.Equal(t, tst.Want, have, tst.In)
+
+		// round trip
+		e2, err := expr.Parse(have)
+		if assert.NoError(t, err, have) {
+			assert.Equal(t, e, e2, have)
+		}
+	}
+
+	// synthetic expressions
+	assert.Equal(t, "%A - -5", expr.Format(&expr.BinExpr{Op: expr.SUB, Lhs: &expr.Field{Name: "A"}, Rhs: expr.NewConst(-5)}))
+	assert.Equal(t, "(-5).X", expr.Format(&expr.Selector{X: expr.NewConst(-5), Name: "X"}))
+
+	// negative constants round trip to the same value
+	for _, e := range []expr.Expr{
+		&expr.UnaryExpr{Op: expr.SUB, X: expr.NewConst(-1)},
+		&expr.UnaryExpr{Op: expr.NOT, X: expr.NewConst(-1)},
+		&expr.UnaryExpr{Op: expr.SUB, X: &expr.Const{Value: -1.5}},
+		&expr.BinExpr{Op: expr.SUB, Lhs: expr.NewConst(-1), Rhs: expr.NewConst(-2)},
+	} {
+		have := expr.Format(e)
+		e2, err := expr.Parse(have)
+		if !assert.NoError(t, err, have) {
+			continue
+		}
+		want, err := expr.Eval(nil, e)
+		if assert.NoError(t, err, have) {
+			v, err := expr.Eval(nil, e2)
+			if assert.NoError(t, err, have) {
+				assert.Equal(t, want, v, have)
+			}
+		}
+	}
+	assert.Equal(t, "-(-1)", expr.Format(&expr.UnaryExpr{Op: expr.SUB, X: expr.NewConst(-1)}))
+}
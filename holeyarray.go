@@ -17,14 +17,32 @@ func (dec *Decoder) holeyArray(v reflect.Value) error {
 	}
 
 	sl := reflect.MakeSlice(v.Type(), ptrs.Len(), ptrs.Len())
+	var (
+		trace = dec.traceElems(v.Type())
+		last  = -1
+	)
+	defer dec.saveElem()()
 	for i := 0; i < ptrs.Len(); i++ {
-		if ptrs.Index(i).IsZero() {
+		if isNullPtr(ptrs.Index(i)) {
 			continue
 		}
+		dec.setElem(i, ptrs.Len(), elemValue(sl, last))
+		last = i
+		start := dec.enterElem(trace, i)
 		if err := dec.decodeValue(sl.Index(i)); err != nil {
 			return err
 		}
+		dec.leaveElem(trace, start, sl.Index(i))
 	}
 	v.Set(sl)
 	return nil
 }
+
+// isNullPtr reports whether an entry of a ptrs slice marks a missing
+// element.
+func isNullPtr(p reflect.Value) bool {
+	if p.Kind() == reflect.Interface {
+		p = p.Elem()
+	}
+	return !p.IsValid() || p.IsZero()
+}
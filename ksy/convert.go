@@ -0,0 +1,401 @@
+package ksy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KlemensWinter/go-binio"
+)
+
+var (
+	// u1, s2, u4le, f8be, ...
+	primRe = regexp.MustCompile(`^([usf])([1248])(le|be)?$`)
+	bitsRe = regexp.MustCompile(`^b[0-9]+(le|be)?$`)
+)
+
+type (
+	converter struct {
+		structs  []*structType
+		types    map[string]*structType // by Kaitai name
+		enums    map[string]*enumType   // by Kaitai name
+		enumList []*enumType
+		attrs    map[string]bool // ids of all attributes
+		problems []string
+	}
+
+	structType struct {
+		Name   string
+		Doc    string
+		Fields []*fieldDef
+
+		spec   *Spec
+		endian string
+	}
+
+	fieldDef struct {
+		Name       string
+		GoType     string
+		SchemaType string
+		Tag        []string
+		Doc        string
+	}
+
+	enumType struct {
+		Name       string
+		Underlying string
+		Values     []enumValue
+	}
+
+	enumValue struct {
+		ID    string
+		Name  string
+		Value int64
+	}
+)
+
+// Schema converts the spec into a runtime schema.
+func (s *Spec) Schema() (*binio.Schema, error) {
+	c, err := s.convert()
+	if err != nil {
+		return nil, err
+	}
+	sch := &binio.Schema{Root: c.structs[0].Name}
+	for _, st := range c.structs {
+		var fields []*binio.SchemaField
+		for _, f := range st.Fields {
+			fields = append(fields, &binio.SchemaField{
+				Name: f.Name,
+				Type: f.SchemaType,
+				Tag:  strings.Join(f.Tag, ","),
+			})
+		}
+		sch.Define(st.Name, fields...)
+	}
+	if err := sch.Compile(); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+func (s *Spec) convert() (*converter, error) {
+	c := &converter{
+		types: make(map[string]*structType),
+		enums: make(map[string]*enumType),
+		attrs: make(map[string]bool),
+	}
+	c.collect(s, s.Meta.ID, "le")
+	for _, st := range c.structs {
+		c.convertStruct(st)
+	}
+	for _, e := range c.enumList {
+		if e.Underlying == "" {
+			e.Underlying = "int64"
+		}
+	}
+	if len(c.problems) > 0 {
+		return nil, &UnsupportedError{Problems: c.problems}
+	}
+	return c, nil
+}
+
+func (c *converter) problem(path string, format string, args ...any) {
+	c.problems = append(c.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+// collect registers s and all nested types and enums, so that they can
+// be referenced before they are defined.
+func (c *converter) collect(s *Spec, name, endian string) {
+	switch e := s.Meta.Endian.(type) {
+	case nil:
+	case string:
+		endian = e
+	default:
+		c.problem(name, "switch-on endianness is not supported")
+	}
+	for key := range s.Extra {
+		if !ignored(key) {
+			c.problem(name, "%s is not supported", key)
+		}
+	}
+	if len(s.Meta.Imports) > 0 {
+		c.problem(name, "imports are not supported")
+	}
+
+	if _, found := c.types[name]; found {
+		c.problem(name, "duplicate type name")
+		return
+	}
+	st := &structType{
+		Name:   goName(name),
+		Doc:    s.Doc,
+		spec:   s,
+		endian: endian,
+	}
+	c.types[name] = st
+	c.structs = append(c.structs, st)
+	for _, a := range s.Seq {
+		if a.ID != "" {
+			c.attrs[a.ID] = true
+		}
+	}
+
+	for _, ename := range sortedKeys(s.Enums) {
+		if _, found := c.enums[ename]; found {
+			c.problem(name, "duplicate enum %q", ename)
+			continue
+		}
+		e := &enumType{Name: goName(ename)}
+		for val, id := range s.Enums[ename] {
+			if m, ok := id.(map[string]any); ok {
+				id = m["id"]
+			}
+			e.Values = append(e.Values, enumValue{
+				ID:    fmt.Sprint(id),
+				Name:  e.Name + goName(fmt.Sprint(id)),
+				Value: val,
+			})
+		}
+		sort.Slice(e.Values, func(i, j int) bool { return e.Values[i].Value < e.Values[j].Value })
+		c.enums[ename] = e
+		c.enumList = append(c.enumList, e)
+	}
+
+	for _, tname := range sortedKeys(s.Types) {
+		c.collect(s.Types[tname], tname, endian)
+	}
+}
+
+func (c *converter) convertStruct(st *structType) {
+	for i, a := range st.spec.Seq {
+		path := fmt.Sprintf("%s.seq[%d]", st.Name, i)
+		if a.ID != "" {
+			path = st.Name + "." + a.ID
+		}
+		if f := c.convertAttr(path, st, a); f != nil {
+			st.Fields = append(st.Fields, f)
+		}
+	}
+}
+
+func (c *converter) convertAttr(path string, st *structType, a *Attr) *fieldDef {
+	n := len(c.problems)
+	f := &fieldDef{
+		Name: "_",
+		Doc:  a.Doc,
+	}
+	if a.ID != "" {
+		f.Name = goName(a.ID)
+	}
+	for key := range a.Extra {
+		if !ignored(key) {
+			c.problem(path, "%s is not supported", key)
+		}
+	}
+
+	expr := func(key string, v any) string {
+		res, err := c.translate(exprString(v))
+		if err != nil {
+			c.problem(path, "%s: %v", key, err)
+		}
+		return res
+	}
+
+	// the type of a single element
+	switch typ := a.Type.(type) {
+	case nil:
+		switch {
+		case a.Contents != nil:
+			buf, err := contents(a.Contents)
+			if err != nil {
+				c.problem(path, "contents: %v", err)
+			}
+			if f.Name == "_" {
+				c.problem(path, "contents without an id are not checked")
+			}
+			f.GoType = fmt.Sprintf("[%d]byte", len(buf))
+			f.Tag = append(f.Tag, "valid=$_ == "+strconv.Quote(string(buf)))
+		case a.Size != nil:
+			if n, ok := a.Size.(int); ok && f.Name == "_" {
+				// skipped fields need a fixed size
+				f.GoType = fmt.Sprintf("[%d]byte", n)
+				break
+			}
+			f.GoType = "[]byte"
+			f.Tag = append(f.Tag, "size="+expr("size", a.Size))
+		default:
+			c.problem(path, "needs a type, size or contents")
+		}
+
+	case string:
+		if a.Contents != nil {
+			c.problem(path, "contents with a type is not supported")
+		}
+		if a.Size != nil && typ != "str" {
+			c.problem(path, "size on type %q (substreams) is not supported", typ)
+		}
+		switch {
+		case typ == "str":
+			if a.Size == nil {
+				c.problem(path, "strings need a size")
+			}
+			enc := a.Encoding
+			if enc == "" {
+				enc = st.spec.Meta.Encoding
+			}
+			switch strings.ToLower(enc) {
+			case "", "ascii", "utf-8", "utf8":
+			default:
+				c.problem(path, "encoding %q is not supported", enc)
+			}
+			f.GoType = "string"
+			f.Tag = append(f.Tag, "size="+expr("size", a.Size))
+		case typ == "strz":
+			c.problem(path, "null terminated strings (strz) are not supported")
+		case bitsRe.MatchString(typ):
+			c.problem(path, "bit-sized integers are not supported")
+		case primRe.MatchString(typ):
+			f.GoType = c.primType(path, st, typ)
+		default:
+			f.GoType = c.userType(path, typ)
+		}
+
+	default:
+		c.problem(path, "switch-on types are not supported")
+	}
+	f.SchemaType = f.GoType
+
+	if a.Enum != "" {
+		c.enumType(path, f, a.Enum)
+	}
+
+	switch a.Repeat {
+	case "":
+	case "expr", "until":
+		if len(f.Tag) > 0 {
+			c.problem(path, "repeated byte arrays and strings are not supported")
+		}
+		f.GoType = "[]" + f.GoType
+		f.SchemaType = "[]" + f.SchemaType
+		if a.Repeat == "expr" {
+			f.Tag = append(f.Tag, "size="+expr("repeat-expr", a.RepeatExpr))
+		} else {
+			f.Tag = append(f.Tag, "until="+expr("repeat-until", a.RepeatUntil))
+		}
+	case "eos":
+		c.problem(path, "repeat: eos is not supported")
+	default:
+		c.problem(path, "invalid repeat %q", a.Repeat)
+	}
+
+	if a.If != nil {
+		f.Tag = append(f.Tag, "if="+expr("if", a.If))
+	}
+
+	if len(c.problems) > n {
+		return nil
+	}
+	if len(f.Tag) > 0 {
+		if _, err := binio.ParseTag(strings.Join(f.Tag, ",")); err != nil {
+			c.problem(path, "%v", err)
+			return nil
+		}
+	}
+	return f
+}
+
+func (c *converter) primType(path string, st *structType, typ string) string {
+	m := primRe.FindStringSubmatch(typ)
+	endian := m[3]
+	if endian == "" {
+		endian = st.endian
+	}
+	if m[2] != "1" && endian == "be" {
+		c.problem(path, "big endian type %q is not supported", typ)
+	}
+	bits := map[string]string{"1": "8", "2": "16", "4": "32", "8": "64"}[m[2]]
+	switch m[1] {
+	case "u":
+		return "uint" + bits
+	case "s":
+		return "int" + bits
+	default:
+		if m[2] != "4" && m[2] != "8" {
+			c.problem(path, "invalid float type %q", typ)
+		}
+		return "float" + bits
+	}
+}
+
+func (c *converter) userType(path, typ string) string {
+	if strings.Contains(typ, "(") {
+		c.problem(path, "parametric types are not supported")
+		return ""
+	}
+	parts := strings.Split(typ, "::")
+	st, found := c.types[parts[len(parts)-1]]
+	if !found {
+		c.problem(path, "unknown type %q", typ)
+		return ""
+	}
+	return st.Name
+}
+
+func (c *converter) enumType(path string, f *fieldDef, name string) {
+	parts := strings.Split(name, "::")
+	e, found := c.enums[parts[len(parts)-1]]
+	if !found {
+		c.problem(path, "unknown enum %q", name)
+		return
+	}
+	if !strings.HasPrefix(f.GoType, "uint") && !strings.HasPrefix(f.GoType, "int") {
+		c.problem(path, "enum %q needs an integer type", name)
+		return
+	}
+	if e.Underlying == "" {
+		e.Underlying = f.GoType
+	}
+	f.GoType = e.Name
+}
+
+// contents converts the value of a contents key, which is either a string
+// or a list of strings and numbers.
+func contents(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []any:
+		var buf []byte
+		for _, itm := range v {
+			switch itm := itm.(type) {
+			case int:
+				if itm < 0 || itm > 255 {
+					return nil, fmt.Errorf("invalid byte %d", itm)
+				}
+				buf = append(buf, byte(itm))
+			case string:
+				if n, err := strconv.ParseUint(itm, 0, 8); err == nil {
+					buf = append(buf, byte(n))
+				} else {
+					buf = append(buf, itm...)
+				}
+			default:
+				return nil, fmt.Errorf("invalid item %v", itm)
+			}
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("invalid contents %v", v)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
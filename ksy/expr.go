@@ -0,0 +1,174 @@
+package ksy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/KlemensWinter/go-binio/expr"
+)
+
+var (
+	ksyKeywords = map[string]string{
+		"and":     "&&",
+		"or":      "||",
+		"not":     "!",
+		"true":    "true",
+		"false":   "false",
+		"_":       "$_",
+		"_index":  "$_index",
+		"_parent": "%_parent",
+		"_root":   "%_root",
+	}
+)
+
+// exprString returns the source of an expression, which YAML may have
+// decoded as a number or a bool.
+func exprString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// translate converts a Kaitai Struct expression into the syntax of the
+// expr package. Field names are converted with goName, enum values are
+// replaced by their numbers. Methods, like .length, are not supported.
+func (c *converter) translate(src string) (string, error) {
+	var (
+		out  strings.Builder
+		rs   = []rune(src)
+		prev rune // last non space rune
+	)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			out.WriteByte(' ')
+			i++
+			continue
+
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (isIdentRune(rs[j]) || rs[j] == '.' && j+1 < len(rs) && unicode.IsDigit(rs[j+1])) {
+				j++
+			}
+			out.WriteString(strings.ReplaceAll(string(rs[i:j]), "_", ""))
+			i = j
+
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(rs) && isIdentRune(rs[j]) {
+				j++
+			}
+			name := string(rs[i:j])
+			// enum value: [type::]enum::value
+			for j+2 < len(rs) && rs[j] == ':' && rs[j+1] == ':' {
+				k := j + 2
+				for k < len(rs) && isIdentRune(rs[k]) {
+					k++
+				}
+				name += "::" + string(rs[j+2:k])
+				j = k
+			}
+			i = j
+
+			switch {
+			case strings.Contains(name, "::"):
+				v, err := c.enumValue(name)
+				if err != nil {
+					return "", err
+				}
+				out.WriteString(strconv.FormatInt(v, 10))
+			case name == "_io":
+				return "", fmt.Errorf("_io is not supported")
+			case prev == '.':
+				// members are attributes, everything else is one of
+				// the methods like .length or .to_i
+				if !c.attrs[name] {
+					return "", fmt.Errorf("method .%s is not supported", name)
+				}
+				out.WriteString(goName(name))
+			default:
+				if kw, found := ksyKeywords[name]; found {
+					out.WriteString(kw)
+				} else {
+					out.WriteString("%" + goName(name))
+				}
+			}
+
+		case r == '"' || r == '\'':
+			j := i + 1
+			for j < len(rs) && rs[j] != r {
+				if rs[j] == '\\' && r == '"' {
+					j++
+				}
+				j++
+			}
+			if j >= len(rs) {
+				return "", fmt.Errorf("unterminated string in %q", src)
+			}
+			if r == '\'' {
+				out.WriteString(strconv.Quote(string(rs[i+1 : j])))
+			} else {
+				out.WriteString(string(rs[i : j+1]))
+			}
+			i = j + 1
+
+		default:
+			out.WriteRune(r)
+			i++
+		}
+		prev = rs[i-1]
+	}
+
+	res := strings.TrimSpace(out.String())
+	if _, err := expr.Parse(res); err != nil {
+		return "", fmt.Errorf("expression %q is not supported (%s: %v)", src, res, err)
+	}
+	return res, nil
+}
+
+func (c *converter) enumValue(name string) (int64, error) {
+	parts := strings.Split(name, "::")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("invalid enum value %q", name)
+	}
+	e, found := c.enums[parts[len(parts)-2]]
+	if !found {
+		return 0, fmt.Errorf("unknown enum %q", name)
+	}
+	id := parts[len(parts)-1]
+	for _, v := range e.Values {
+		if v.ID == id {
+			return v.Value, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown enum value %q", name)
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// goName converts a snake_case Kaitai identifier into an exported Go name.
+func goName(id string) string {
+	var sb strings.Builder
+	for _, part := range strings.Split(id, "_") {
+		if part == "" {
+			continue
+		}
+		rs := []rune(part)
+		rs[0] = unicode.ToUpper(rs[0])
+		sb.WriteString(string(rs))
+	}
+	name := sb.String()
+	if name == "" {
+		return "_"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
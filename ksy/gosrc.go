@@ -0,0 +1,62 @@
+package ksy
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+// GoSource generates Go structs with bin tags for the spec, in the
+// package pkg. Contents are decoded into byte arrays which are checked
+// with a valid option.
+func (s *Spec) GoSource(pkg string) ([]byte, error) {
+	c, err := s.convert()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated from %s.ksy; DO NOT EDIT.\n\n", s.Meta.ID)
+	fmt.Fprintf(&buf, "package %s\n", pkg)
+
+	for _, st := range c.structs {
+		buf.WriteString("\n")
+		writeDoc(&buf, st.Doc)
+		fmt.Fprintf(&buf, "type %s struct {\n", st.Name)
+		for _, f := range st.Fields {
+			writeDoc(&buf, f.Doc)
+			fmt.Fprintf(&buf, "%s %s", f.Name, f.GoType)
+			if len(f.Tag) > 0 {
+				fmt.Fprintf(&buf, " `bin:%s`", strconv.Quote(strings.Join(f.Tag, ",")))
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("}\n")
+	}
+
+	for _, e := range c.enumList {
+		fmt.Fprintf(&buf, "\ntype %s %s\n", e.Name, e.Underlying)
+		if len(e.Values) == 0 {
+			continue
+		}
+		buf.WriteString("\nconst (\n")
+		for _, v := range e.Values {
+			fmt.Fprintf(&buf, "%s %s = %d\n", v.Name, e.Name, v.Value)
+		}
+		buf.WriteString(")\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+func writeDoc(buf *bytes.Buffer, doc string) {
+	doc = strings.TrimSpace(doc)
+	if doc == "" {
+		return
+	}
+	for _, line := range strings.Split(doc, "\n") {
+		fmt.Fprintf(buf, "// %s\n", strings.TrimSpace(line))
+	}
+}
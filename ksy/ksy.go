@@ -0,0 +1,92 @@
+// Package ksy converts Kaitai Struct format descriptions (.ksy files) into
+// binio schemas or Go structs with bin tags.
+//
+// Only the subset of Kaitai Struct that can be expressed with bin tags is
+// supported: seq, types, enums, contents, size, if and repeat with
+// repeat-expr or repeat-until. Everything else is reported by an
+// *UnsupportedError listing all problems found in the file.
+package ksy
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Spec is a parsed .ksy file or one of its types.
+	Spec struct {
+		Meta  Meta                     `yaml:"meta"`
+		Doc   string                   `yaml:"doc"`
+		Seq   []*Attr                  `yaml:"seq"`
+		Types map[string]*Spec         `yaml:"types"`
+		Enums map[string]map[int64]any `yaml:"enums"`
+
+		Extra map[string]any `yaml:",inline"`
+	}
+
+	Meta struct {
+		ID       string   `yaml:"id"`
+		Endian   any      `yaml:"endian"`
+		Encoding string   `yaml:"encoding"`
+		Imports  []string `yaml:"imports"`
+
+		Extra map[string]any `yaml:",inline"`
+	}
+
+	// Attr is an entry of a seq.
+	Attr struct {
+		ID          string `yaml:"id"`
+		Type        any    `yaml:"type"`
+		Size        any    `yaml:"size"`
+		Repeat      string `yaml:"repeat"`
+		RepeatExpr  any    `yaml:"repeat-expr"`
+		RepeatUntil any    `yaml:"repeat-until"`
+		If          any    `yaml:"if"`
+		Contents    any    `yaml:"contents"`
+		Enum        string `yaml:"enum"`
+		Encoding    string `yaml:"encoding"`
+		Doc         string `yaml:"doc"`
+
+		Extra map[string]any `yaml:",inline"`
+	}
+
+	// UnsupportedError lists all features of a .ksy file that could not be
+	// converted.
+	UnsupportedError struct {
+		Problems []string
+	}
+)
+
+func (err *UnsupportedError) Error() string {
+	return fmt.Sprintf("ksy: %d unsupported feature(s):\n\t%s",
+		len(err.Problems), strings.Join(err.Problems, "\n\t"))
+}
+
+// Parse parses the content of a .ksy file.
+func Parse(data []byte) (*Spec, error) {
+	var s Spec
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("ksy: %w", err)
+	}
+	if s.Meta.ID == "" {
+		return nil, fmt.Errorf("ksy: missing meta/id")
+	}
+	return &s, nil
+}
+
+// Read reads and parses a .ksy file from rd.
+func Read(rd io.Reader) (*Spec, error) {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// ignored reports whether an unknown key carries only documentation.
+func ignored(key string) bool {
+	return key == "doc-ref" || strings.HasPrefix(key, "-")
+}
@@ -0,0 +1,224 @@
+package ksy_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/KlemensWinter/go-binio/ksy"
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleKSY = `
+meta:
+  id: sample_file
+  endian: le
+doc: A sample file.
+seq:
+  - id: magic
+    contents: "SMPL"
+  - id: version
+    type: u2
+  - id: num_chunks
+    type: u4
+  - id: chunks
+    type: chunk
+    repeat: expr
+    repeat-expr: num_chunks
+  - id: trailer
+    type: u1
+    repeat: until
+    repeat-until: _ == 0
+types:
+  chunk:
+    seq:
+      - id: kind
+        type: u1
+        enum: chunk_kind
+      - id: len
+        type: u1
+      - id: body
+        size: len
+      - id: extra
+        type: u4le
+        if: kind == chunk_kind::data
+      - id: name
+        type: str
+        size: 4
+        encoding: ASCII
+enums:
+  chunk_kind:
+    1: text
+    2:
+      id: data
+      doc: binary data
+`
+
+func sampleData() []byte {
+	var buf bytes.Buffer
+	for _, v := range []any{
+		[]byte("SMPL"), uint16(1), uint32(2),
+		uint8(1), uint8(2), []byte("ab"), []byte("ab\x00\x00"),
+		uint8(2), uint8(0), uint32(7), []byte("cdef"),
+		uint8(5), uint8(0),
+	} {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			panic(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestSchema(t *testing.T) {
+	spec, err := ksy.Parse([]byte(sampleKSY))
+	if !assert.NoError(t, err) {
+		return
+	}
+	s, err := spec.Schema()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "SampleFile", s.Root)
+
+	v, err := binio.UnmarshalSchema(bytes.NewReader(sampleData()), s)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]any{
+		"Magic":     []byte("SMPL"),
+		"Version":   uint16(1),
+		"NumChunks": uint32(2),
+		"Chunks": []any{
+			map[string]any{
+				"Kind":  uint8(1),
+				"Len":   uint8(2),
+				"Body":  []byte("ab"),
+				"Extra": uint32(0),
+				"Name":  "ab",
+			},
+			map[string]any{
+				"Kind":  uint8(2),
+				"Len":   uint8(0),
+				"Body":  []byte{},
+				"Extra": uint32(7),
+				"Name":  "cdef",
+			},
+		},
+		"Trailer": []any{uint8(5), uint8(0)},
+	}, v)
+
+	buf := sampleData()
+	copy(buf, "SMPX")
+	_, err = binio.UnmarshalSchema(bytes.NewReader(buf), s)
+	assert.ErrorIs(t, err, binio.ErrInvalidValue)
+}
+
+func TestSchema_skipped(t *testing.T) {
+	spec, err := ksy.Parse([]byte(`
+meta:
+  id: skipped
+seq:
+  - id: len
+    type: u1
+  - size: len
+  - size: 2
+  - id: value
+    type: u1
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	s, err := spec.Schema()
+	if !assert.NoError(t, err) {
+		return
+	}
+	v, err := binio.UnmarshalSchema(bytes.NewReader([]byte{3, 'a', 'b', 'c', 0, 0, 7}), s)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]any{"Len": uint8(3), "Value": uint8(7)}, v)
+	}
+
+	src, err := spec.GoSource("skipped")
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(src), "_     []byte `bin:\"size=%Len\"`")
+	}
+}
+
+func TestGoSource(t *testing.T) {
+	spec, err := ksy.Parse([]byte(sampleKSY))
+	if !assert.NoError(t, err) {
+		return
+	}
+	src, err := spec.GoSource("sample")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = parser.ParseFile(token.NewFileSet(), "sample.go", src, 0)
+	assert.NoError(t, err)
+
+	for _, want := range []string{
+		"// A sample file.\ntype SampleFile struct {",
+		"Magic     [4]byte `bin:\"valid=$_ == \\\"SMPL\\\"\"`",
+		"Chunks    []Chunk `bin:\"size=%NumChunks\"`",
+		"Trailer   []uint8 `bin:\"until=$_ == 0\"`",
+		"Kind  ChunkKind",
+		"Extra uint32 `bin:\"if=%Kind == 2\"`",
+		"type ChunkKind uint8",
+		"ChunkKindData ChunkKind = 2",
+	} {
+		assert.Contains(t, string(src), want)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	spec, err := ksy.Parse([]byte(`
+meta:
+  id: bad
+  endian: be
+seq:
+  - id: a
+    type: u2
+  - id: b
+    type: strz
+  - id: c
+    type: u1
+    repeat: eos
+  - id: d
+    type: u1
+    process: xor(0x55)
+  - id: e
+    type: u1
+    if: _io.eof
+  - id: f
+    type: u1
+    -webide-representation: "{f}"
+  - id: g
+    size: f.length
+  - contents: [1, 2]
+instances:
+  foo:
+    value: 1
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = spec.Schema()
+	var ue *ksy.UnsupportedError
+	if assert.True(t, errors.As(err, &ue)) {
+		assert.ElementsMatch(t, []string{
+			"bad: instances is not supported",
+			"Bad.a: big endian type \"u2\" is not supported",
+			"Bad.b: null terminated strings (strz) are not supported",
+			"Bad.c: repeat: eos is not supported",
+			"Bad.d: process is not supported",
+			"Bad.e: if: _io is not supported",
+			"Bad.g: size: method .length is not supported",
+			"Bad.seq[7]: contents without an id are not checked",
+		}, ue.Problems)
+	}
+}
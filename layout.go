@@ -0,0 +1,288 @@
+package binio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KlemensWinter/go-binio/expr"
+)
+
+var (
+	ErrVariableSize = errors.New("variable size")
+)
+
+// Layout describes how a type is encoded. It is returned by Describe.
+type Layout struct {
+	Name string // field name, the type name for the root
+	Type reflect.Type
+	Tag  *Tag
+
+	// Offset is the static offset relative to the root, or -1 if it
+	// depends on the data.
+	Offset int64
+
+	// Size is the fixed encoded size, or -1 if it depends on the data.
+	Size int64
+
+	Fields []*Layout // fields of structs
+	Elem   *Layout   // first element of arrays and slices
+
+	// why the size is variable: the path of the field below this one
+	// and the reason
+	varPath []string
+	varWhy  string
+	err     error
+}
+
+// SizeOf returns the fixed encoded size of T or an error wrapping
+// ErrVariableSize naming the first field with a size that depends on the
+// data.
+func SizeOf[T any]() (int, error) {
+	var v T
+	return ValueSize(reflect.TypeOf(&v).Elem())
+}
+
+// ValueSize returns the fixed encoded size of typ, see SizeOf.
+func ValueSize(typ reflect.Type) (int, error) {
+	l := describe(typ, nil, typ.Name(), 0, nil)
+	if err := l.sizeErr(); err != nil {
+		return 0, err
+	}
+	return int(l.Size), nil
+}
+
+// Describe returns the layout of typ with the names, types and tags of all
+// fields, and their static offsets and sizes where they are known.
+func Describe(typ reflect.Type) *Layout {
+	return describe(typ, nil, typ.Name(), 0, nil)
+}
+
+// Walk calls fn for l and all fields and elements below it in depth-first
+// order. Elements are named [i]. fn may keep path, it is not modified
+// later. If fn returns an error, Walk stops and returns it.
+func (l *Layout) Walk(fn func(path []string, l *Layout) error) error {
+	return l.walk(nil, fn)
+}
+
+func (l *Layout) walk(path []string, fn func(path []string, l *Layout) error) error {
+	if err := fn(path, l); err != nil {
+		return err
+	}
+	// siblings must not share the array of path
+	path = slices.Clip(path)
+	for _, f := range l.Fields {
+		if err := f.walk(append(path, f.Name), fn); err != nil {
+			return err
+		}
+	}
+	if l.Elem != nil {
+		return l.Elem.walk(append(path, l.Elem.Name), fn)
+	}
+	return nil
+}
+
+// String formats the layout as an indented tree.
+func (l *Layout) String() string {
+	var sb strings.Builder
+	num := func(n int64) string {
+		if n < 0 {
+			return "?"
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	l.Walk(func(path []string, l *Layout) error {
+		fmt.Fprintf(&sb, "%s%s %s offset=%s size=%s\n",
+			strings.Repeat("  ", len(path)), l.Name, l.Type, num(l.Offset), num(l.Size))
+		return nil
+	})
+	return sb.String()
+}
+
+// variable marks the size of l as variable.
+func (l *Layout) variable(why string) *Layout {
+	l.Size = -1
+	if l.varWhy == "" {
+		l.varWhy = why
+	}
+	return l
+}
+
+// inherit marks l as variable if the size of its field or element sub is.
+func (l *Layout) inherit(sub *Layout) {
+	if sub.Size >= 0 {
+		return
+	}
+	l.Size = -1
+	if l.err == nil {
+		l.err = sub.err
+	}
+	if l.varWhy == "" && sub.varWhy != "" {
+		l.varWhy = sub.varWhy
+		l.varPath = append([]string{sub.Name}, sub.varPath...)
+	}
+}
+
+// sizeErr returns why the size of l is not fixed.
+func (l *Layout) sizeErr() error {
+	switch {
+	case l.err != nil:
+		return l.err
+	case l.Size >= 0:
+		return nil
+	case len(l.varPath) == 0:
+		return fmt.Errorf("%w: %s %s", ErrVariableSize, l.Type, l.varWhy)
+	default:
+		return fmt.Errorf("%w: field %s %s", ErrVariableSize, JoinPath(l.varPath), l.varWhy)
+	}
+}
+
+// constEnv resolves constants and types but not the names of integer
+// types, which are the count types of dynarrays and dynstrings.
+var constEnv = &expr.Env{
+	Ident: func(name string) (any, bool) {
+		if v, found := lookupConst(name); found {
+			return v, true
+		}
+		if t, found := lookupType(name); found {
+			return t, true
+		}
+		return nil, false
+	},
+	Func: lookupFunc,
+}
+
+// constSize returns the value of a constant size expression, like 4,
+// MAX_NAME, (MAX_NAME + 1) * 2 or sizeof(Header).
+func constSize(e expr.Expr) (int64, bool) {
+	if e == nil {
+		return 0, false
+	}
+	p, err := expr.Compile(e, constEnv)
+	if err != nil {
+		return 0, false
+	}
+	v, _ := p.Const()
+	n, ok := v.(int64)
+	return n, ok
+}
+
+// describe builds the layout of typ at offset off; visiting holds the
+// struct types being described to stop on recursive types.
+func describe(typ reflect.Type, tag *Tag, name string, off int64, visiting []reflect.Type) *Layout {
+	l := &Layout{Name: name, Type: typ, Tag: tag, Offset: off}
+
+	// elem describes the element of an array or slice with n elements
+	elem := func(n int64) {
+		l.Elem = describe(typ.Elem(), nil, "[0]", off, visiting)
+		switch {
+		case n < 0:
+			l.variable("has a variable length")
+		case l.Elem.Size < 0:
+			l.inherit(l.Elem)
+		default:
+			l.Size = n * l.Elem.Size
+		}
+	}
+
+	if _, found := decoderFuncs[typ]; found || reflect.PointerTo(typ).Implements(unmarshalerType) {
+		return l.variable("has a custom decoder")
+	}
+	if n, found := sizes[typ.Kind()]; found {
+		l.Size = int64(n)
+		return l
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		l.Size = 1
+	case reflect.Float32:
+		l.Size = 4
+	case reflect.Float64:
+		l.Size = 8
+
+	case reflect.Array:
+		elem(int64(typ.Len()))
+
+	case reflect.Slice:
+		n := int64(-1)
+		if !tag.IsDynArray() && !tag.IsHoleyArray() && !tag.hasUntil() {
+			if c, ok := constSize(tag.sizeExpr()); ok {
+				n = c
+			}
+		}
+		elem(n)
+
+	case reflect.String:
+		if n, ok := constSize(tag.sizeExpr()); ok && !tag.IsDynString() {
+			l.Size = n
+			break
+		}
+		l.variable("has a variable length")
+
+	case reflect.Ptr:
+		inner := describe(typ.Elem(), tag, name, off, visiting)
+		l.Size, l.Fields, l.Elem = inner.Size, inner.Fields, inner.Elem
+		l.varPath, l.varWhy, l.err = inner.varPath, inner.varWhy, inner.err
+
+	case reflect.Struct:
+		if isLazy(typ) {
+			if n, ok := constSize(tag.sizeExpr()); ok {
+				l.Size = n
+				break
+			}
+			inner := describe(reflect.New(typ).Interface().(lazyValue).elemType(), nil, name, off, visiting)
+			l.Size, l.varWhy, l.err = inner.Size, inner.varWhy, inner.err
+			break
+		}
+		for _, t := range visiting {
+			if t == typ {
+				return l.variable("is recursive")
+			}
+		}
+		describeStruct(l, append(visiting, typ))
+
+	default:
+		l.variable("has the unsupported type " + typ.String())
+	}
+	return l
+}
+
+func describeStruct(l *Layout, visiting []reflect.Type) {
+	def, err := buildStructDef(l.Type, visiting)
+	if err != nil {
+		l.Size = -1
+		l.err = err
+		return
+	}
+
+	var size int64 // -1 once a field has a variable size
+	for _, f := range def.Fields {
+		off := int64(-1)
+		if l.Offset >= 0 && size >= 0 {
+			off = l.Offset + size
+		}
+		if f.isSpan {
+			l.Fields = append(l.Fields, &Layout{Name: f.Name, Type: f.Typ, Tag: f.Tag, Offset: off})
+			continue
+		}
+
+		fl := describe(f.Typ, f.Tag, f.Name, off, visiting)
+		if f.Tag != nil && f.Tag.If != nil {
+			fl.variable("is conditional")
+		}
+		l.Fields = append(l.Fields, fl)
+		l.inherit(fl)
+		if size >= 0 && fl.Size >= 0 {
+			size += fl.Size
+		} else {
+			size = -1
+		}
+	}
+	if l.Size == 0 && size >= 0 {
+		l.Size = size
+	}
+}
@@ -0,0 +1,110 @@
+package binio_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizeOf(t *testing.T) {
+	type Point struct {
+		X, Y float32
+	}
+	type Fixed struct {
+		Magic  [4]byte
+		Flag   bool
+		_      [3]byte
+		Scale  float64
+		Points [2]Point
+		Name   string  `bin:"size=8"`
+		Data   []int16 `bin:"size=3"`
+		Pos    binio.Span
+	}
+	type Entry struct {
+		Len  uint8
+		Name string `bin:"size=%Len"`
+	}
+	type Variable struct {
+		A       uint32
+		Entries [2]Entry
+	}
+	type Conditional struct {
+		A uint8
+		B uint8 `bin:"if=%A"`
+	}
+
+	n, err := binio.SizeOf[Fixed]()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 4+1+3+8+2*8+8+6, n)
+	}
+
+	n, err = binio.SizeOf[uint16]()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	_, err = binio.SizeOf[Variable]()
+	assert.ErrorIs(t, err, binio.ErrVariableSize)
+	assert.EqualError(t, err, "variable size: field Entries[0].Name has a variable length")
+
+	_, err = binio.SizeOf[Conditional]()
+	assert.EqualError(t, err, "variable size: field B is conditional")
+
+	_, err = binio.SizeOf[[]byte]()
+	assert.ErrorIs(t, err, binio.ErrVariableSize)
+}
+
+func TestDescribe(t *testing.T) {
+	type Header struct {
+		Magic [2]byte
+		Count uint16
+	}
+	type File struct {
+		Header  Header
+		Count   uint8
+		Entries []uint32 `bin:"size=%Count"`
+		Tail    uint8
+	}
+
+	l := binio.Describe(reflect.TypeOf(File{}))
+	assert.Equal(t, int64(-1), l.Size)
+	assert.Equal(t, `File binio_test.File offset=0 size=?
+  Header binio_test.Header offset=0 size=4
+    Magic [2]uint8 offset=0 size=2
+      [0] uint8 offset=0 size=1
+    Count uint16 offset=2 size=2
+  Count uint8 offset=4 size=1
+  Entries []uint32 offset=5 size=?
+    [0] uint32 offset=5 size=4
+  Tail uint8 offset=? size=1
+`, l.String())
+
+	var paths []string
+	l.Walk(func(path []string, l *binio.Layout) error {
+		if l.Size == 4 {
+			paths = append(paths, binio.JoinPath(path))
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"Header", "Entries[0]"}, paths)
+}
+
+func TestLayout_Walk(t *testing.T) {
+	type C struct{ X, Y uint8 }
+	type B struct{ C C }
+	type A struct{ B B }
+	type File struct{ A A }
+
+	// fn may keep the paths
+	var kept [][]string
+	binio.Describe(reflect.TypeOf(File{})).Walk(func(path []string, l *binio.Layout) error {
+		kept = append(kept, path)
+		return nil
+	})
+	var paths []string
+	for _, p := range kept {
+		paths = append(paths, binio.JoinPath(p))
+	}
+	assert.Equal(t, []string{"", "A", "A.B", "A.B.C", "A.B.C.X", "A.B.C.Y"}, paths)
+}
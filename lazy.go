@@ -0,0 +1,147 @@
+package binio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+var (
+	ErrLazyUnbound = errors.New("lazy value was not decoded")
+
+	lazyType = reflect.TypeOf((*lazyValue)(nil)).Elem()
+)
+
+type (
+	// Lazy is a field whose value is decoded on the first call to Get.
+	//
+	// While decoding the enclosing struct the decoder only records the
+	// position of the field and skips it. The number of bytes to skip is
+	// taken from the size tag or, if there is none, from the static size
+	// of T:
+	//
+	//	type Entry struct {
+	//		Len  uint32
+	//		Data binio.Lazy[Payload] `bin:"size=%Len"`
+	//	}
+	//
+	// If the decoder reads from an io.ReaderAt (like *os.File or
+	// *bytes.Reader) the data is read again from there; otherwise the raw
+	// bytes are buffered in memory. Tag variables of enclosing structs are
+	// not available when T is decoded.
+	//
+	// Get is safe for concurrent use.
+	Lazy[T any] struct {
+		l *lazy[T]
+	}
+
+	lazy[T any] struct {
+		buf  []byte // raw data, if not read from src
+		src  io.ReaderAt
+		off  int64
+		size int64
+
+		once sync.Once
+		val  T
+		err  error
+	}
+
+	lazyValue interface {
+		bind(src io.ReaderAt, buf []byte, off, size int64)
+		elemType() reflect.Type
+	}
+)
+
+func (l *Lazy[T]) bind(src io.ReaderAt, buf []byte, off, size int64) {
+	l.l = &lazy[T]{
+		buf:  buf,
+		src:  src,
+		off:  off,
+		size: size,
+	}
+}
+
+func (l *Lazy[T]) elemType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Get decodes the value on the first call and returns the cached result
+// on all following calls.
+func (l Lazy[T]) Get() (T, error) {
+	if l.l == nil {
+		var zero T
+		return zero, ErrLazyUnbound
+	}
+	l.l.once.Do(l.l.decode)
+	return l.l.val, l.l.err
+}
+
+func (l *lazy[T]) decode() {
+	var dec *Decoder
+	if l.buf != nil {
+		dec = NewBytesDecoder(l.buf)
+	} else {
+		dec = NewDecoder(io.NewSectionReader(l.src, l.off, l.size))
+	}
+	l.err = dec.Decode(&l.val)
+}
+
+// Offset returns the position of the value in the input.
+func (l Lazy[T]) Offset() int64 {
+	if l.l == nil {
+		return 0
+	}
+	return l.l.off
+}
+
+// Size returns the number of bytes reserved for the value.
+func (l Lazy[T]) Size() int64 {
+	if l.l == nil {
+		return 0
+	}
+	return l.l.size
+}
+
+func isLazy(typ reflect.Type) bool {
+	return reflect.PointerTo(typ).Implements(lazyType)
+}
+
+// lazyValue records the position of v and skips size bytes. If size is
+// negative the static size of the lazy element type is used.
+func (dec *Decoder) lazyValue(v reflect.Value, size int64) error {
+	lv := v.Addr().Interface().(lazyValue)
+	if size < 0 {
+		n, err := ValueSize(lv.elemType())
+		if err != nil {
+			return fmt.Errorf("lazy field needs a size: %w", err)
+		}
+		size = int64(n)
+	}
+
+	switch {
+	case dec.buf != nil:
+		off := dec.pos
+		buf, err := dec.next(int(size))
+		if err != nil {
+			return err
+		}
+		lv.bind(nil, buf, off, size)
+	case dec.ra != nil:
+		off := dec.base + dec.pos
+		if _, err := dec.sk.Seek(size, io.SeekCurrent); err != nil {
+			return err
+		}
+		dec.pos += size
+		lv.bind(dec.ra, nil, off, size)
+	default:
+		off := dec.pos
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(dec, buf); err != nil {
+			return err
+		}
+		lv.bind(nil, buf, off, size)
+	}
+	return nil
+}
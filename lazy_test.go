@@ -0,0 +1,160 @@
+package binio_test
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/stretchr/testify/assert"
+)
+
+type lazyPayload struct {
+	A uint32
+	B uint16
+}
+
+type lazyEntry struct {
+	Len  uint16
+	Data binio.Lazy[lazyPayload] `bin:"size=%Len"`
+	Next binio.Lazy[uint64]
+	End  uint8
+}
+
+func lazyTestData() []byte {
+	return pack(
+		uint32(0xff), // some header which is not part of the entry
+		uint16(8),
+		uint32(1), uint16(2), uint16(0), // payload with padding
+		uint64(3),
+		uint8(4),
+	)
+}
+
+func checkLazyEntry(t *testing.T, e *lazyEntry) {
+	t.Helper()
+
+	assert.Equal(t, uint16(8), e.Len)
+	assert.Equal(t, uint8(4), e.End)
+	assert.Equal(t, int64(6), e.Data.Offset())
+	assert.Equal(t, int64(8), e.Data.Size())
+	assert.Equal(t, int64(14), e.Next.Offset())
+	assert.Equal(t, int64(8), e.Next.Size())
+
+	p, err := e.Data.Get()
+	if assert.NoError(t, err) {
+		assert.Equal(t, lazyPayload{A: 1, B: 2}, p)
+	}
+	n, err := e.Next.Get()
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(3), n)
+	}
+}
+
+func TestLazy_readerAt(t *testing.T) {
+	rd := bytes.NewReader(lazyTestData())
+	_, _ = rd.Seek(4, io.SeekStart)
+
+	var e lazyEntry
+	if assert.NoError(t, binio.Unmarshal(rd, &e)) {
+		checkLazyEntry(t, &e)
+	}
+}
+
+func TestLazy_reader(t *testing.T) {
+	buf := lazyTestData()
+	rd := io.MultiReader(bytes.NewReader(buf[4:])) // hide io.ReaderAt
+
+	var e lazyEntry
+	if assert.NoError(t, binio.Unmarshal(rd, &e)) {
+		// offsets are relative to the start of the reader
+		assert.Equal(t, int64(2), e.Data.Offset())
+		p, err := e.Data.Get()
+		if assert.NoError(t, err) {
+			assert.Equal(t, lazyPayload{A: 1, B: 2}, p)
+		}
+	}
+}
+
+func TestLazy_bytes(t *testing.T) {
+	buf := lazyTestData()
+	dec := binio.NewBytesDecoder(buf)
+	assert.NoError(t, dec.Skip(4))
+
+	var e lazyEntry
+	if assert.NoError(t, dec.Decode(&e)) {
+		checkLazyEntry(t, &e)
+	}
+}
+
+type lazyHeader struct {
+	Magic [2]byte
+	Count uint16
+}
+
+type lazyRecord struct {
+	Len  uint8
+	Name string `bin:"size=%Len"`
+}
+
+type lazyMulti struct {
+	Payload binio.Lazy[lazyPayload] `bin:"size=8"`
+	Header  binio.Lazy[lazyHeader]  `bin:"size=4"`
+	Record  binio.Lazy[lazyRecord]  `bin:"size=3"`
+}
+
+func TestLazy_concurrent(t *testing.T) {
+	var e lazyEntry
+	if !assert.NoError(t, binio.UnmarshalBytes(lazyTestData()[4:], &e)) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := e.Data.Get()
+			assert.NoError(t, err)
+			assert.Equal(t, uint32(1), p.A)
+		}()
+	}
+	wg.Wait()
+
+	// values of different types decode their struct definitions
+	// concurrently
+	data := pack(uint32(1), uint16(2), uint16(0), []byte("MZ"), uint16(3), uint8(2), []byte("ab"))
+	var m lazyMulti
+	if !assert.NoError(t, binio.UnmarshalBytes(data, &m)) {
+		return
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			p, err := m.Payload.Get()
+			assert.NoError(t, err)
+			assert.Equal(t, lazyPayload{A: 1, B: 2}, p)
+		}()
+		go func() {
+			defer wg.Done()
+			h, err := m.Header.Get()
+			assert.NoError(t, err)
+			assert.Equal(t, lazyHeader{Magic: [2]byte{'M', 'Z'}, Count: 3}, h)
+		}()
+		go func() {
+			defer wg.Done()
+			r, err := m.Record.Get()
+			assert.NoError(t, err)
+			assert.Equal(t, "ab", r.Name)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLazy_unbound(t *testing.T) {
+	var l binio.Lazy[uint32]
+	_, err := l.Get()
+	assert.ErrorIs(t, err, binio.ErrLazyUnbound)
+}
@@ -0,0 +1,91 @@
+package binio
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"github.com/KlemensWinter/go-binio/expr"
+)
+
+// SetLogger sets a logger which receives a debug event for each decoded
+// field and element and for the field that failed to decode. Logging is
+// disabled by default or if l is nil.
+//
+// The events have the attributes path, offset and length. Events of
+// fields with tags additionally carry the evaluated size, if and ptrs
+// (as the number of entries) and the variables bound by the tag.
+func (dec *Decoder) SetLogger(l *slog.Logger) {
+	dec.logger = l
+	dec.path = dec.path[:0]
+}
+
+func (dec *Decoder) logField(msg string, span Span, err error) {
+	attrs := []slog.Attr{
+		slog.String("path", JoinPath(dec.path)),
+		slog.Int64("offset", span.Offset),
+		slog.Int64("length", span.Length),
+	}
+	if n := len(dec.path); n > 0 && !strings.HasPrefix(dec.path[n-1], "[") && len(dec.stack) > 0 {
+		attrs = append(attrs, dec.current().logAttrs()...)
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	dec.logger.LogAttrs(context.Background(), slog.LevelDebug, msg, attrs...)
+}
+
+// logFailed logs the field which caused err. Errors which already have a
+// context come from nested fields and have already been logged.
+func (dec *Decoder) logFailed(start int64, err error) {
+	if dec.logger == nil {
+		return
+	}
+	if _, ok := err.(*DecodingError); ok {
+		return
+	}
+	dec.logField("field failed", Span{Offset: start, Length: dec.pos - start}, err)
+}
+
+// logVars logs the variables visible when evaluating ex failed.
+func (dec *Decoder) logVars(ex expr.Expr, err error) {
+	if dec.logger == nil {
+		return
+	}
+	var levels []any
+	for _, state := range dec.stack {
+		levels = append(levels, state.Vars)
+	}
+	dec.logger.LogAttrs(context.Background(), slog.LevelDebug, "undefined variable",
+		slog.String("path", JoinPath(dec.path)),
+		slog.String("expr", ex.String()),
+		slog.Any("error", err),
+		slog.Any("vars", levels),
+	)
+}
+
+func (state *state) logAttrs() []slog.Attr {
+	if state.Field == nil || state.Field.Tag == nil {
+		return nil
+	}
+	var (
+		tag   = state.Field.Tag
+		attrs []slog.Attr
+	)
+	if tag.Size != nil {
+		attrs = append(attrs, slog.Int("size", state.Size))
+	}
+	if tag.If != nil {
+		attrs = append(attrs, slog.Any("if", state.Condition))
+	}
+	if tag.Ptrs != nil {
+		if ptrs := reflect.ValueOf(state.Ptrs); ptrs.Kind() == reflect.Slice {
+			attrs = append(attrs, slog.Int("ptrs", ptrs.Len()))
+		}
+	}
+	if len(state.Vars) > 0 {
+		attrs = append(attrs, slog.Any("vars", state.Vars))
+	}
+	return attrs
+}
@@ -0,0 +1,67 @@
+package binio_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/stretchr/testify/assert"
+)
+
+func logEvents(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var events []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var ev map[string]any
+		if assert.NoError(t, json.Unmarshal(line, &ev)) {
+			delete(ev, "time")
+			delete(ev, "level")
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func TestDecoder_SetLogger(t *testing.T) {
+	type Struct struct {
+		Len  uint8
+		Data []uint8 `bin:"size=%Len,$n=%Len"`
+		Ext  uint16  `bin:"if=%Len > 1"`
+	}
+
+	var buf bytes.Buffer
+	dec := binio.NewDecoder(bytes.NewReader([]byte{2, 7, 8, 1, 0}))
+	dec.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	var res Struct
+	if !assert.NoError(t, dec.Decode(&res)) {
+		return
+	}
+	assert.Equal(t, []map[string]any{
+		{"msg": "field", "path": "Len", "offset": 0.0, "length": 1.0},
+		{"msg": "field", "path": "Data", "offset": 1.0, "length": 2.0, "size": 2.0, "vars": map[string]any{"n": 2.0}},
+		{"msg": "field", "path": "Ext", "offset": 3.0, "length": 2.0, "if": true},
+	}, logEvents(t, &buf))
+}
+
+func TestDecoder_SetLogger_failed(t *testing.T) {
+	type Struct struct {
+		A    uint8
+		Data []uint8 `bin:"size=$missing"`
+	}
+
+	var buf bytes.Buffer
+	dec := binio.NewDecoder(bytes.NewReader([]byte{1, 2}))
+	dec.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	var res Struct
+	assert.Error(t, dec.Decode(&res))
+
+	events := logEvents(t, &buf)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "undefined variable", events[1]["msg"])
+		assert.Equal(t, "$missing", events[1]["expr"])
+		assert.Equal(t, "Data", events[1]["path"])
+	}
+}
@@ -0,0 +1,63 @@
+package binio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// Records returns an iterator over a sequence of records of type T stored
+// back to back in r.
+//
+// The iteration stops without an error if r ends exactly at a record
+// boundary. If r ends in the middle of a record, the last pair yielded
+// holds a *DecodingError wrapping io.ErrUnexpectedEOF with the offset and
+// field path where the data ended.
+//
+//	for rec, err := range binio.Records[Packet](f) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Records[T any](r io.Reader) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		dec := NewDecoder(r)
+		for {
+			var rec T
+			start := dec.Pos()
+			err := dec.Decode(&rec)
+			switch {
+			case err == nil && dec.Pos() == start:
+				yield(rec, fmt.Errorf("record at %d has no data", start))
+				return
+			case err == nil:
+				if !yield(rec, nil) {
+					return
+				}
+			case errors.Is(err, io.EOF) && dec.Pos() == start:
+				return // clean end
+			default:
+				yield(rec, dec.truncated(err))
+				return
+			}
+		}
+	}
+}
+
+// truncated turns an EOF encountered in the middle of a value into
+// io.ErrUnexpectedEOF.
+func (dec *Decoder) truncated(err error) error {
+	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	e := &DecodingError{Pos: dec.Pos()}
+	var de *DecodingError
+	if errors.As(err, &de) {
+		e.Pos = de.Pos
+		e.Path = de.Path
+	}
+	e.Err = io.ErrUnexpectedEOF
+	return e
+}
@@ -0,0 +1,89 @@
+package binio_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	Len  uint8
+	Data []byte `bin:"size=%Len"`
+}
+
+func TestRecords(t *testing.T) {
+	buf := pack(
+		uint8(2), []byte{1, 2},
+		uint8(0),
+		uint8(3), []byte{3, 4, 5},
+	)
+
+	var have []testRecord
+	for rec, err := range binio.Records[testRecord](bytes.NewReader(buf)) {
+		if !assert.NoError(t, err) {
+			return
+		}
+		have = append(have, rec)
+	}
+	assert.Equal(t, []testRecord{
+		{2, []byte{1, 2}},
+		{0, nil},
+		{3, []byte{3, 4, 5}},
+	}, have)
+}
+
+func TestRecords_empty(t *testing.T) {
+	for _, err := range binio.Records[testRecord](bytes.NewReader(nil)) {
+		t.Fatalf("unexpected record, err=%v", err)
+	}
+}
+
+func TestRecords_truncated(t *testing.T) {
+	testdata := []struct {
+		Buf  []byte
+		Recs int
+		Pos  int64
+	}{
+		{pack(uint8(2), []byte{1, 2}, uint8(3), []byte{3}), 1, 5},
+		{pack(uint8(2), []byte{1, 2}, uint8(3)), 1, 4},
+		{pack(uint16(4)), 0, 2},
+	}
+
+	for _, tst := range testdata {
+		var (
+			n   int
+			err error
+		)
+		for _, err = range binio.Records[testRecord](bytes.NewReader(tst.Buf)) {
+			if err != nil {
+				break
+			}
+			n++
+		}
+		assert.Equal(t, tst.Recs, n)
+		if assert.ErrorIs(t, err, io.ErrUnexpectedEOF) {
+			var de *binio.DecodingError
+			if assert.True(t, errors.As(err, &de)) {
+				assert.Equal(t, tst.Pos, de.Pos)
+			}
+		}
+	}
+}
+
+func TestRecords_break(t *testing.T) {
+	buf := pack(uint32(1), uint32(2), uint32(3))
+
+	var have []uint32
+	for v, err := range binio.Records[uint32](bytes.NewReader(buf)) {
+		assert.NoError(t, err)
+		have = append(have, v)
+		if len(have) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []uint32{1, 2}, have)
+}
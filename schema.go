@@ -0,0 +1,491 @@
+package binio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/KlemensWinter/go-binio/expr"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrUnknownType = errors.New("unknown type")
+)
+
+var (
+	schemaPrims = map[string]reflect.Type{
+		"bool":    reflect.TypeOf(false),
+		"byte":    reflect.TypeOf(uint8(0)),
+		"uint8":   reflect.TypeOf(uint8(0)),
+		"uint16":  reflect.TypeOf(uint16(0)),
+		"uint32":  reflect.TypeOf(uint32(0)),
+		"uint64":  reflect.TypeOf(uint64(0)),
+		"int8":    reflect.TypeOf(int8(0)),
+		"int16":   reflect.TypeOf(int16(0)),
+		"int32":   reflect.TypeOf(int32(0)),
+		"int64":   reflect.TypeOf(int64(0)),
+		"float32": reflect.TypeOf(float32(0)),
+		"float64": reflect.TypeOf(float64(0)),
+		"string":  reflect.TypeOf(""),
+	}
+)
+
+type (
+	// Schema describes a binary layout at runtime, without a Go struct.
+	//
+	// Types maps the name of a struct type to its fields. A field type is
+	// the name of a primitive type (uint8..uint64, int8..int64, byte,
+	// float32, float64, bool, string), the name of another struct type or
+	// a slice or array of those written as []T or [N]T. The field tag uses
+	// the same options as the bin struct tag:
+	//
+	//	root: File
+	//	types:
+	//	  File:
+	//	    - {name: Count, type: uint16}
+	//	    - {name: Entries, type: "[]Entry", tag: "size=%Count"}
+	//	  Entry:
+	//	    - {name: ID, type: uint32}
+	//	    - {name: Name, type: string, tag: "type=dynstring,size=uint8"}
+	//
+	// Structs are decoded into map[string]any, slices and arrays of
+	// uint8 into []byte and all other slices and arrays into []any.
+	Schema struct {
+		Root  string                    `json:"root" yaml:"root"`
+		Types map[string][]*SchemaField `json:"types" yaml:"types"`
+
+		root *schemaType
+	}
+
+	SchemaField struct {
+		Name string `json:"name" yaml:"name"`
+		Type string `json:"type" yaml:"type"`
+		Tag  string `json:"tag,omitempty" yaml:"tag,omitempty"`
+	}
+
+	schemaType struct {
+		name string
+		kind reflect.Kind
+		typ  reflect.Type // primitive types
+
+		len  int         // arrays
+		elem *schemaType // slices and arrays
+
+		def    *structDef // structs
+		fields []*schemaField
+	}
+
+	schemaField struct {
+		*field
+		typ *schemaType
+	}
+)
+
+// ParseSchema parses a schema from a YAML or JSON document and compiles
+// it.
+func ParseSchema(data []byte) (*Schema, error) {
+	var s Schema
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	if err := s.Compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// LoadSchema reads a schema document from rd, see ParseSchema.
+func LoadSchema(rd io.Reader) (*Schema, error) {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSchema(data)
+}
+
+// Define adds the struct type name with the given fields to the schema.
+func (s *Schema) Define(name string, fields ...*SchemaField) *Schema {
+	if s.Types == nil {
+		s.Types = make(map[string][]*SchemaField)
+	}
+	s.Types[name] = fields
+	s.root = nil
+	return s
+}
+
+// Compile checks the schema and parses all field tags. It is called by
+// DecodeSchema if necessary, but must be called before a Schema is used
+// from multiple goroutines.
+func (s *Schema) Compile() error {
+	if s.Root == "" {
+		return errors.New("schema: missing root type")
+	}
+	structs := make(map[string]*schemaType)
+	root, err := s.resolve(s.Root, structs)
+	if err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+	if root.kind != reflect.Struct {
+		return fmt.Errorf("schema: root type %q is not a struct", s.Root)
+	}
+	s.root = root
+	return nil
+}
+
+func (s *Schema) resolve(name string, structs map[string]*schemaType) (*schemaType, error) {
+	name = strings.TrimSpace(name)
+	if typ, found := schemaPrims[name]; found {
+		return &schemaType{name: name, kind: typ.Kind(), typ: typ}, nil
+	}
+	if t, found := structs[name]; found {
+		return t, nil
+	}
+
+	if strings.HasPrefix(name, "[") {
+		end := strings.Index(name, "]")
+		if end == -1 {
+			return nil, fmt.Errorf("invalid type %q", name)
+		}
+		elem, err := s.resolve(name[end+1:], structs)
+		if err != nil {
+			return nil, err
+		}
+		t := &schemaType{name: name, kind: reflect.Slice, elem: elem}
+		if n := strings.TrimSpace(name[1:end]); n != "" {
+			l, err := strconv.Atoi(n)
+			if err != nil || l < 0 {
+				return nil, fmt.Errorf("invalid array length in %q", name)
+			}
+			t.kind = reflect.Array
+			t.len = l
+		}
+		return t, nil
+	}
+
+	fields, found := s.Types[name]
+	if !found {
+		return nil, fmt.Errorf("%w %q", ErrUnknownType, name)
+	}
+	t := &schemaType{
+		name: name,
+		kind: reflect.Struct,
+		def:  &structDef{Name: name},
+	}
+	structs[name] = t
+	for _, f := range fields {
+		sf := &schemaField{
+			field: &field{Struct: t.def, Name: f.Name},
+		}
+		if f.Tag != "" {
+			tg, err := ParseTag(f.Tag)
+			if err == nil {
+				err = tg.compile(identEnv)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", name, f.Name, err)
+			}
+			sf.Tag = tg
+		}
+		typ, err := s.resolve(f.Type, structs)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", name, f.Name, err)
+		}
+		sf.typ = typ
+		if typ.kind == reflect.String && (sf.Tag == nil || sf.Tag.Size == nil) {
+			return nil, fmt.Errorf("%s.%s: %w", name, f.Name, ErrMissingSize)
+		}
+		t.def.Fields = append(t.def.Fields, sf.field)
+		t.fields = append(t.fields, sf)
+	}
+	return t, nil
+}
+
+// size returns the encoded size of fixed size types.
+func (t *schemaType) size() (int, error) {
+	switch t.kind {
+	case reflect.Array:
+		n, err := t.elem.size()
+		return n * t.len, err
+	case reflect.Struct:
+		var sum int
+		for _, f := range t.fields {
+			n, err := f.typ.size()
+			if err != nil {
+				return 0, err
+			}
+			sum += n
+		}
+		return sum, nil
+	case reflect.Slice, reflect.String:
+		return 0, fmt.Errorf("sizeof() unhandled type %s", t.name)
+	default:
+		return int(t.typ.Size()), nil
+	}
+}
+
+// zero returns the value of t for fields whose condition is false.
+func (t *schemaType) zero() any {
+	switch t.kind {
+	case reflect.Slice:
+		if t.elem.kind == reflect.Uint8 {
+			return []byte(nil)
+		}
+		return []any(nil)
+	case reflect.Array:
+		if t.elem.kind == reflect.Uint8 {
+			return make([]byte, t.len)
+		}
+		l := make([]any, t.len)
+		for i := range l {
+			l[i] = t.elem.zero()
+		}
+		return l
+	case reflect.Struct:
+		m := make(map[string]any, len(t.fields))
+		for _, f := range t.fields {
+			if f.Name != "_" {
+				m[f.Name] = f.typ.zero()
+			}
+		}
+		return m
+	default:
+		return reflect.Zero(t.typ).Interface()
+	}
+}
+
+// DecodeSchema decodes the root type of s.
+func (dec *Decoder) DecodeSchema(s *Schema) (any, error) {
+	if s.root == nil {
+		if err := s.Compile(); err != nil {
+			return nil, err
+		}
+	}
+	return dec.schemaValue(s.root)
+}
+
+// UnmarshalSchema decodes the root type of s from rd.
+func UnmarshalSchema(rd io.Reader, s *Schema) (any, error) {
+	dec := NewDecoder(rd)
+	return dec.DecodeSchema(s)
+}
+
+func (dec *Decoder) schemaValue(t *schemaType) (v any, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			if er, ok := e.(error); ok {
+				err = er
+			} else {
+				err = fmt.Errorf("error: %s", e)
+			}
+		}
+	}()
+
+	switch t.kind {
+	case reflect.Struct:
+		return dec.schemaStruct(t)
+	case reflect.Array:
+		return dec.schemaElems(t.elem, t.len)
+	case reflect.Slice, reflect.String:
+		return nil, fmt.Errorf("%s needs a size", t.name)
+	default:
+		val := reflect.New(t.typ).Elem()
+		if err := dec.decodeValue(val); err != nil {
+			return nil, err
+		}
+		return val.Interface(), nil
+	}
+}
+
+func (dec *Decoder) schemaStruct(t *schemaType) (map[string]any, error) {
+	m := make(map[string]any, len(t.fields))
+	fields := dec.pushScope(func(name string) (any, bool) {
+		v, found := m[name]
+		if !found {
+			return nil, false
+		}
+		return fieldValue(reflect.ValueOf(v)), true
+	})
+	defer dec.popScope()
+
+	for _, f := range t.fields {
+		start := dec.enterField(f.Name)
+		dec.beginField()
+		dec.evalField(fields, f.field)
+
+		v, err := dec.schemaField(f, fields)
+		if err == nil && f.Name != "_" {
+			err = dec.valid(fieldValue(reflect.ValueOf(v)), fields)
+		}
+		if err != nil {
+			dec.logFailed(start, err)
+			return nil, dec.addErrorContext(err, f.Name)
+		}
+		dec.leaveField(start, reflect.ValueOf(v), f.Name == "_")
+		if f.Name != "_" {
+			m[f.Name] = v
+		}
+		dec.endField()
+	}
+	return m, nil
+}
+
+func (dec *Decoder) schemaField(f *schemaField, fields fieldFunc) (any, error) {
+	cur := dec.current()
+	if cur.Condition != nil && !expr.Bool(cur.Condition) {
+		return f.typ.zero(), nil
+	}
+
+	if f.Name == "_" { // skipped
+		elem := reflect.Invalid
+		if f.typ.elem != nil {
+			elem = f.typ.elem.kind
+		}
+		if sizedSkip(f.typ.kind, elem, f.Tag) {
+			return nil, dec.Skip(int64(cur.Size))
+		}
+		n, err := f.typ.size()
+		if err != nil {
+			return nil, err
+		}
+		return nil, dec.Skip(int64(n))
+	}
+
+	switch f.typ.kind {
+	case reflect.Slice:
+		switch {
+		case f.Tag != nil && f.Tag.Until != nil:
+			return dec.schemaUntil(f.typ.elem, fields)
+		case f.Tag.IsDynArray():
+			return dec.schemaElems(f.typ.elem, dec.Uint(cur.Size))
+		case f.Tag.IsHoleyArray():
+			return dec.schemaHoleyArray(f.typ.elem)
+		default:
+			return dec.schemaElems(f.typ.elem, cur.Size)
+		}
+
+	case reflect.String:
+		size := cur.Size
+		if f.Tag.IsDynString() {
+			size = dec.Uint(cur.Size)
+		} else if size == 0 {
+			return nil, fmt.Errorf("string with size 0")
+		}
+		buf, err := dec.readBytes(size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read string: %w", err)
+		}
+		return string(bytes.TrimRight(buf, "\x00")), nil
+
+	default:
+		return dec.schemaValue(f.typ)
+	}
+}
+
+func (dec *Decoder) schemaElems(elem *schemaType, size int) (any, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("negative slice size %d", size)
+	}
+	if size >= maxArraySize {
+		return nil, fmt.Errorf("array to big! have=%d, max=%d", size, maxArraySize)
+	}
+	if elem.kind == reflect.Uint8 {
+		return dec.readBytes(size)
+	}
+	if size == 0 {
+		return []any(nil), nil
+	}
+	if len(dec.stack) == 0 { // the root type
+		dec.beginField()
+		defer dec.endField()
+	}
+	l := make([]any, size)
+	trace := dec.tracing()
+	defer dec.saveElem()()
+	for i := range l {
+		dec.setElem(i, size, schemaElem(l, i-1))
+		start := dec.enterElem(trace, i)
+		v, err := dec.schemaValue(elem)
+		if err != nil {
+			return nil, err
+		}
+		dec.leaveElem(trace, start, reflect.ValueOf(v))
+		l[i] = v
+	}
+	return l, nil
+}
+
+// schemaElem returns the element i of l for $_last.
+func schemaElem(l []any, i int) any {
+	if i < 0 {
+		return nil
+	}
+	return fieldValue(reflect.ValueOf(l[i]))
+}
+
+func (dec *Decoder) schemaUntil(elem *schemaType, fields fieldFunc) (any, error) {
+	var l []any
+	trace := dec.tracing() && elem.kind != reflect.Uint8
+	defer dec.saveElem()()
+	for {
+		if len(l) >= maxArraySize {
+			return nil, fmt.Errorf("array to big! max=%d", maxArraySize)
+		}
+		dec.setElem(len(l), -1, schemaElem(l, len(l)-1))
+		start := dec.enterElem(trace, len(l))
+		v, err := dec.schemaValue(elem)
+		if err != nil {
+			return nil, err
+		}
+		dec.leaveElem(trace, start, reflect.ValueOf(v))
+		l = append(l, v)
+
+		done, err := dec.until(fieldValue(reflect.ValueOf(v)), fields)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return l, nil
+		}
+	}
+}
+
+func (dec *Decoder) schemaHoleyArray(elem *schemaType) (any, error) {
+	ptrs := reflect.ValueOf(dec.current().Ptrs)
+	if ptrs.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("ptrs must be a slice, got %s", ptrs.Kind())
+	}
+	if ptrs.Len() >= maxArraySize {
+		return nil, fmt.Errorf("array to big! have=%d, max=%d", ptrs.Len(), maxArraySize)
+	}
+	if ptrs.Len() == 0 {
+		return []any(nil), nil
+	}
+
+	var (
+		l     = make([]any, ptrs.Len())
+		trace = dec.tracing() && elem.kind != reflect.Uint8
+		last  = -1
+	)
+	defer dec.saveElem()()
+	for i := range l {
+		if isNullPtr(ptrs.Index(i)) {
+			l[i] = elem.zero()
+			continue
+		}
+		dec.setElem(i, len(l), schemaElem(l, last))
+		last = i
+		start := dec.enterElem(trace, i)
+		v, err := dec.schemaValue(elem)
+		if err != nil {
+			return nil, err
+		}
+		dec.leaveElem(trace, start, reflect.ValueOf(v))
+		l[i] = v
+	}
+	return l, nil
+}
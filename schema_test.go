@@ -0,0 +1,219 @@
+package binio_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSchemaYAML = `
+root: File
+types:
+  File:
+    - {name: Count, type: uint16}
+    - {name: HasExtra, type: bool}
+    - {name: _, type: "[2]byte"}
+    - {name: Entries, type: "[]Entry", tag: "size=%Count"}
+    - {name: Extra, type: float32, tag: "if=%HasExtra"}
+    - {name: Name, type: string, tag: "type=dynstring,size=uint8"}
+  Entry:
+    - {name: ID, type: uint32}
+    - {name: Data, type: "[]byte", tag: "type=dynarray,size=uint16"}
+`
+
+const testSchemaJSON = `{
+  "root": "File",
+  "types": {
+    "File": [
+      {"name": "Count", "type": "uint16"},
+      {"name": "HasExtra", "type": "bool"},
+      {"name": "_", "type": "[2]byte"},
+      {"name": "Entries", "type": "[]Entry", "tag": "size=%Count"},
+      {"name": "Extra", "type": "float32", "tag": "if=%HasExtra"},
+      {"name": "Name", "type": "string", "tag": "type=dynstring,size=uint8"}
+    ],
+    "Entry": [
+      {"name": "ID", "type": "uint32"},
+      {"name": "Data", "type": "[]byte", "tag": "type=dynarray,size=uint16"}
+    ]
+  }
+}`
+
+func testSchemaData() []byte {
+	return pack(
+		uint16(2), false, [2]byte{},
+		uint32(10), uint16(2), []byte{1, 2},
+		uint32(11), uint16(0),
+		uint8(3), []byte("foo"),
+	)
+}
+
+func TestSchema(t *testing.T) {
+	want := map[string]any{
+		"Count":    uint16(2),
+		"HasExtra": false,
+		"Entries": []any{
+			map[string]any{"ID": uint32(10), "Data": []byte{1, 2}},
+			map[string]any{"ID": uint32(11), "Data": []byte{}},
+		},
+		"Extra": float32(0),
+		"Name":  "foo",
+	}
+
+	for _, doc := range []string{testSchemaYAML, testSchemaJSON} {
+		s, err := binio.ParseSchema([]byte(doc))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		have, err := binio.UnmarshalSchema(bytes.NewReader(testSchemaData()), s)
+		if assert.NoError(t, err) {
+			assert.Equal(t, want, have)
+		}
+	}
+}
+
+func TestSchema_sameAsStruct(t *testing.T) {
+	type Entry struct {
+		ID   uint32
+		Data []byte `bin:"type=dynarray,size=uint16"`
+	}
+	type File struct {
+		Count    uint16
+		HasExtra bool
+		_        [2]byte
+		Entries  []Entry `bin:"size=%Count"`
+		Extra    float32 `bin:"if=%HasExtra"`
+		Name     string  `bin:"type=dynstring,size=uint8"`
+	}
+
+	var f File
+	if !assert.NoError(t, binio.Unmarshal(bytes.NewReader(testSchemaData()), &f)) {
+		return
+	}
+
+	s := new(binio.Schema)
+	s.Root = "File"
+	s.Define("File",
+		&binio.SchemaField{Name: "Count", Type: "uint16"},
+		&binio.SchemaField{Name: "HasExtra", Type: "bool"},
+		&binio.SchemaField{Name: "_", Type: "[2]byte"},
+		&binio.SchemaField{Name: "Entries", Type: "[]Entry", Tag: "size=%Count"},
+		&binio.SchemaField{Name: "Extra", Type: "float32", Tag: "if=%HasExtra"},
+		&binio.SchemaField{Name: "Name", Type: "string", Tag: "type=dynstring,size=uint8"},
+	)
+	s.Define("Entry",
+		&binio.SchemaField{Name: "ID", Type: "uint32"},
+		&binio.SchemaField{Name: "Data", Type: "[]byte", Tag: "type=dynarray,size=uint16"},
+	)
+
+	v, err := binio.UnmarshalSchema(bytes.NewReader(testSchemaData()), s)
+	if !assert.NoError(t, err) {
+		return
+	}
+	m := v.(map[string]any)
+	assert.Equal(t, f.Count, m["Count"])
+	assert.Equal(t, f.Name, m["Name"])
+	entries := m["Entries"].([]any)
+	if assert.Len(t, entries, len(f.Entries)) {
+		for i, e := range f.Entries {
+			assert.Equal(t, e.ID, entries[i].(map[string]any)["ID"])
+		}
+	}
+}
+
+func TestSchema_holeyArray(t *testing.T) {
+	s := new(binio.Schema)
+	s.Root = "File"
+	s.Define("File",
+		&binio.SchemaField{Name: "Ptrs", Type: "[3]uint32"},
+		&binio.SchemaField{Name: "Values", Type: "[]uint16", Tag: "type=holeyarray,ptrs=%Ptrs"},
+	)
+
+	buf := pack([3]uint32{1, 0, 1}, uint16(5), uint16(6))
+
+	v, err := binio.UnmarshalSchema(bytes.NewReader(buf), s)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []any{uint16(5), uint16(0), uint16(6)}, v.(map[string]any)["Values"])
+	}
+}
+
+func TestSchema_errors(t *testing.T) {
+	testdata := []struct {
+		Doc  string
+		Want error
+	}{
+		{`{root: Foo, types: {}}`, binio.ErrUnknownType},
+		{`{root: Foo, types: {Foo: [{name: A, type: "[]Bar"}]}}`, binio.ErrUnknownType},
+		{`{root: Foo, types: {Foo: [{name: A, type: string}]}}`, binio.ErrMissingSize},
+	}
+
+	for _, tst := range testdata {
+		_, err := binio.ParseSchema([]byte(tst.Doc))
+		assert.ErrorIs(t, err, tst.Want, "doc: %s", tst.Doc)
+	}
+}
+
+func TestSchema_decodingError(t *testing.T) {
+	s, err := binio.ParseSchema([]byte(testSchemaYAML))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = binio.UnmarshalSchema(bytes.NewReader(testSchemaData()[:12]), s)
+	var de *binio.DecodingError
+	if assert.True(t, errors.As(err, &de)) {
+		assert.Equal(t, []string{"Entries", "Data"}, de.Path)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestSchema_memberAccess(t *testing.T) {
+	s, err := binio.ParseSchema([]byte(`
+root: File
+types:
+  File:
+    - {name: Header, type: Header}
+    - {name: Entries, type: "[]Entry", tag: "size=%Header.Count"}
+  Header:
+    - {name: Count, type: uint8}
+    - {name: Size, type: uint8}
+  Entry:
+    - {name: Data, type: "[]byte", tag: "size=%_parent.Header.Size"}
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	have, err := binio.UnmarshalSchema(bytes.NewReader(pack(uint8(2), uint8(1), []byte("ab"))), s)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []any{
+			map[string]any{"Data": []byte("a")},
+			map[string]any{"Data": []byte("b")},
+		}, have.(map[string]any)["Entries"])
+	}
+}
+
+func TestSchema_elemVars(t *testing.T) {
+	s, err := binio.ParseSchema([]byte(`
+root: File
+types:
+  File:
+    - {name: Lengths, type: "[2]uint8"}
+    - {name: Entries, type: "[]Entry", tag: "size=2"}
+  Entry:
+    - {name: Data, type: "[]byte", tag: "size=%_parent.Lengths[$_index]"}
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	have, err := binio.UnmarshalSchema(bytes.NewReader(pack([2]uint8{1, 2}, []byte("abc"))), s)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []any{
+			map[string]any{"Data": []byte("a")},
+			map[string]any{"Data": []byte("bc")},
+		}, have.(map[string]any)["Entries"])
+	}
+}
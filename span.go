@@ -0,0 +1,142 @@
+package binio
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Span is a range of bytes in the decoded data.
+	//
+	// Struct fields of type Span are not read from the data but set to
+	// the span of the struct after it has been decoded. With the tag
+	// `bin:"offset=%Field"` they get the span of the sibling Field
+	// instead. Integer fields with an offset tag get the offset only.
+	Span struct {
+		Offset int64
+		Length int64
+	}
+
+	// FieldInfo describes a decoded field or element, see Decoder.OnField.
+	FieldInfo struct {
+		Path    []string // field names, elements are written as [i]
+		Span    Span
+		Value   any  // nil for skipped and unexported fields
+		Skipped bool // the field is named _
+	}
+)
+
+// setSpans fills the span fields of the struct v. spans holds the spans
+// of the fields of v and whole the span of v itself.
+func setSpans(v reflect.Value, def *structDef, spans []Span, whole Span) {
+	for i, f := range def.Fields {
+		if !f.isSpan {
+			continue
+		}
+		s := whole
+		if f.SpanOf >= 0 {
+			s = spans[f.SpanOf]
+		}
+		switch fv := v.Field(i); {
+		case fv.Type() == spanType:
+			fv.Set(reflect.ValueOf(s))
+		case fv.CanInt():
+			fv.SetInt(s.Offset)
+		default:
+			fv.SetUint(uint64(s.Offset))
+		}
+	}
+}
+
+// End returns the offset of the first byte after the span.
+func (s Span) End() int64 {
+	return s.Offset + s.Length
+}
+
+// Contains reports whether the offset off lies within the span.
+func (s Span) Contains(off int64) bool {
+	return off >= s.Offset && off < s.End()
+}
+
+// JoinPath joins the path of a field like "Entries[2].Name".
+func JoinPath(path []string) string {
+	var sb strings.Builder
+	for i, p := range path {
+		if i > 0 && !strings.HasPrefix(p, "[") {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(p)
+	}
+	return sb.String()
+}
+
+// OnField sets fn to be called after each field of a struct and each
+// element of a slice or array has been decoded. Fields come before the
+// struct they belong to. Elements of byte slices and arrays are not
+// reported individually.
+//
+// The path and value passed to fn are only valid during the call.
+func (dec *Decoder) OnField(fn func(info FieldInfo)) {
+	dec.onField = fn
+	dec.path = dec.path[:0]
+}
+
+// enterField starts a field and returns its start offset.
+func (dec *Decoder) enterField(name string) int64 {
+	if dec.tracing() {
+		dec.path = append(dec.path, name)
+	}
+	return dec.pos
+}
+
+// enterElem starts the i-th element of a slice or array if trace is
+// set and returns its start offset.
+func (dec *Decoder) enterElem(trace bool, i int) int64 {
+	if trace {
+		return dec.enterField("[" + strconv.Itoa(i) + "]")
+	}
+	return dec.pos
+}
+
+// leaveElem is the counterpart of enterElem.
+func (dec *Decoder) leaveElem(trace bool, start int64, v reflect.Value) {
+	if trace {
+		dec.leaveField(start, v, false)
+	}
+}
+
+// leaveField reports the field started at start to the OnField function
+// and the logger.
+func (dec *Decoder) leaveField(start int64, v reflect.Value, skipped bool) {
+	if !dec.tracing() {
+		return
+	}
+	span := Span{Offset: start, Length: dec.pos - start}
+	if dec.logger != nil {
+		dec.logField("field", span, nil)
+	}
+	if dec.onField != nil {
+		info := FieldInfo{
+			Path:    dec.path,
+			Span:    span,
+			Skipped: skipped,
+		}
+		if !skipped && v.IsValid() && v.CanInterface() {
+			info.Value = v.Interface()
+		}
+		dec.onField(info)
+	}
+	dec.path = dec.path[:len(dec.path)-1]
+}
+
+// tracing reports whether field paths have to be tracked.
+func (dec *Decoder) tracing() bool {
+	return dec.onField != nil || dec.logger != nil
+}
+
+// traceElems reports whether the elements of a slice or array of typ are
+// traced.
+func (dec *Decoder) traceElems(typ reflect.Type) bool {
+	return dec.tracing() && typ.Elem().Kind() != reflect.Uint8
+}
@@ -0,0 +1,126 @@
+package binio_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/stretchr/testify/assert"
+)
+
+type spanEvent struct {
+	Path string
+	Span binio.Span
+}
+
+func TestDecoder_OnField(t *testing.T) {
+	type Point struct {
+		X, Y int16
+	}
+	type Struct struct {
+		Magic  [2]byte
+		_      [2]byte
+		Count  uint8
+		Points []Point `bin:"size=%Count"`
+	}
+
+	var (
+		res    Struct
+		events []spanEvent
+	)
+	dec := binio.NewDecoder(bytes.NewReader(pack([2]byte{'P', 'T'}, [2]byte{}, uint8(2), int16(1), int16(2), int16(3), int16(4))))
+	dec.OnField(func(info binio.FieldInfo) {
+		events = append(events, spanEvent{binio.JoinPath(info.Path), info.Span})
+		if info.Skipped {
+			assert.Nil(t, info.Value)
+		}
+	})
+	if !assert.NoError(t, dec.Decode(&res)) {
+		return
+	}
+	assert.Equal(t, []spanEvent{
+		{"Magic", binio.Span{0, 2}},
+		{"_", binio.Span{2, 2}},
+		{"Count", binio.Span{4, 1}},
+		{"Points[0].X", binio.Span{5, 2}},
+		{"Points[0].Y", binio.Span{7, 2}},
+		{"Points[0]", binio.Span{5, 4}},
+		{"Points[1].X", binio.Span{9, 2}},
+		{"Points[1].Y", binio.Span{11, 2}},
+		{"Points[1]", binio.Span{9, 4}},
+		{"Points", binio.Span{5, 8}},
+	}, events)
+}
+
+func TestDecoder_OnField_schema(t *testing.T) {
+	s, err := binio.ParseSchema([]byte(`
+root: File
+types:
+  File:
+    - {name: Count, type: uint8}
+    - {name: Values, type: "[]uint16", tag: "size=%Count"}
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var events []spanEvent
+	dec := binio.NewBytesDecoder(pack(uint8(2), uint16(1), uint16(2)))
+	dec.OnField(func(info binio.FieldInfo) {
+		events = append(events, spanEvent{binio.JoinPath(info.Path), info.Span})
+	})
+	if _, err := dec.DecodeSchema(s); assert.NoError(t, err) {
+		assert.Equal(t, []spanEvent{
+			{"Count", binio.Span{0, 1}},
+			{"Values[0]", binio.Span{1, 2}},
+			{"Values[1]", binio.Span{3, 2}},
+			{"Values", binio.Span{1, 4}},
+		}, events)
+	}
+}
+
+func TestSpanFields(t *testing.T) {
+	type Entry struct {
+		Len  uint8
+		Name string `bin:"size=%Len"`
+
+		NameSpan binio.Span `bin:"offset=%Name"`
+		Pos      int64      `bin:"offset"`
+		Span     binio.Span
+	}
+	type Struct struct {
+		Magic   [2]byte
+		Entries []Entry `bin:"size=2"`
+	}
+
+	var res Struct
+	err := binio.UnmarshalBytes(pack([2]byte{}, uint8(3), []byte("foo"), uint8(1), []byte("x")), &res)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, binio.Span{Offset: 3, Length: 3}, res.Entries[0].NameSpan)
+	assert.Equal(t, int64(2), res.Entries[0].Pos)
+	assert.Equal(t, binio.Span{Offset: 2, Length: 4}, res.Entries[0].Span)
+	assert.Equal(t, binio.Span{Offset: 7, Length: 1}, res.Entries[1].NameSpan)
+	assert.Equal(t, binio.Span{Offset: 6, Length: 2}, res.Entries[1].Span)
+}
+
+func TestSpanFields_invalid(t *testing.T) {
+	for name, v := range map[string]any{
+		"unknown field": &struct {
+			A uint8
+			S binio.Span `bin:"offset=%B"`
+		}{},
+		"no field": &struct {
+			A uint8
+			S binio.Span `bin:"offset=1"`
+		}{},
+		"invalid type": &struct {
+			A uint8
+			S float32 `bin:"offset=%A"`
+		}{},
+	} {
+		err := binio.UnmarshalBytes([]byte{1}, v)
+		assert.ErrorIs(t, err, binio.ErrInvalidTagOption, name)
+	}
+}
@@ -1,13 +1,24 @@
 package binio
 
-import "reflect"
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/KlemensWinter/go-binio/expr"
+)
 
 const (
 	tagName = "bin"
 )
 
 var (
-	cache = map[reflect.Type]*structDef{}
+	// cache holds the generated struct definitions, guarded by cacheMu;
+	// Lazy values may be decoded concurrently
+	cache   = map[reflect.Type]*structDef{}
+	cacheMu sync.RWMutex
+
+	spanType = reflect.TypeOf(Span{})
 )
 
 type (
@@ -19,11 +30,18 @@ type (
 		Name string
 		Typ  reflect.Type
 		Tag  *Tag
+
+		// for span fields: the index of the field whose span is
+		// captured, or -1 for the enclosing struct
+		SpanOf int
+		isSpan bool
 	}
 
 	structDef struct {
 		Name   string
 		Fields []*field
+
+		hasSpans bool
 	}
 )
 
@@ -45,7 +63,17 @@ func checkField(f *field) error {
 }
 
 func generateStructDef(v reflect.Type) (*structDef, error) {
-	if d, found := cache[v]; found {
+	return buildStructDef(v, []reflect.Type{v})
+}
+
+// buildStructDef generates the definition of the struct v. visiting holds
+// the structs whose definitions are being built, including v; sizeof in
+// their tags fails for them instead of recursing forever.
+func buildStructDef(v reflect.Type, visiting []reflect.Type) (*structDef, error) {
+	cacheMu.RLock()
+	d, found := cache[v]
+	cacheMu.RUnlock()
+	if found {
 		return d, nil
 	}
 	if v.Kind() != reflect.Struct {
@@ -65,7 +93,10 @@ func generateStructDef(v reflect.Type) (*structDef, error) {
 		if str, found := f.Tag.Lookup(tagName); found {
 			tg, err := ParseTag(str)
 			if err != nil {
-				panic(err)
+				return nil, fmt.Errorf("%s.%s: %w", v, f.Name, err)
+			}
+			if err := tg.compile(structEnv(v, visiting)); err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", v, f.Name, err)
 			}
 			field.Tag = tg
 		}
@@ -76,6 +107,100 @@ func generateStructDef(v reflect.Type) (*structDef, error) {
 
 		def.Fields = append(def.Fields, field)
 	}
+	if err := def.resolveSpans(); err != nil {
+		return nil, err
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	// keep the definition of a concurrent call
+	if d, found := cache[v]; found {
+		return d, nil
+	}
 	cache[v] = def
 	return def, nil
 }
+
+// identValue resolves the identifiers in expressions: the names of
+// integer types to their sizes, registered constants and types.
+func identValue(name string) (any, bool) {
+	if size := IntSize(name); size != -1 {
+		return size, true
+	}
+	if v, found := lookupConst(name); found {
+		return v, true
+	}
+	if t, found := lookupType(name); found {
+		return t, true
+	}
+	return nil, false
+}
+
+// identEnv compiles expressions whose fields are not known.
+var identEnv = &expr.Env{Ident: identValue, Func: lookupFunc}
+
+// structEnv compiles the expressions in the tags of the struct typ, see
+// buildStructDef for visiting.
+func structEnv(typ reflect.Type, visiting []reflect.Type) *expr.Env {
+	return &expr.Env{
+		Field: func(name string) (reflect.Type, bool) {
+			if isScopeRef(name) {
+				return nil, true
+			}
+			f, found := typ.FieldByName(name)
+			return f.Type, found
+		},
+		Ident: identValue,
+		Func: func(name string) (expr.Func, bool) {
+			if name == "sizeof" {
+				return sizeOf(visiting), true
+			}
+			return lookupFunc(name)
+		},
+	}
+}
+
+// resolveSpans finds the fields referenced by span fields. Span fields
+// have the type Span or an offset tag; they are not decoded but filled
+// after the struct has been decoded.
+func (def *structDef) resolveSpans() error {
+	for _, f := range def.Fields {
+		if f.Typ != spanType && !f.Tag.IsOffset() {
+			continue
+		}
+		switch f.Typ.Kind() {
+		case reflect.Struct:
+			if f.Typ != spanType {
+				return fmt.Errorf("%w: offset field %s must be a Span or an integer", ErrInvalidTagOption, f.Name)
+			}
+		case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		default:
+			return fmt.Errorf("%w: offset field %s must be a Span or an integer", ErrInvalidTagOption, f.Name)
+		}
+		f.isSpan = true
+		f.SpanOf = -1
+		def.hasSpans = true
+		if f.Tag == nil || f.Tag.Offset == nil {
+			continue
+		}
+
+		ref, ok := f.Tag.Offset.(*expr.Field)
+		if !ok {
+			return fmt.Errorf("%w: offset of %s must be a field, got %s", ErrInvalidTagOption, f.Name, f.Tag.Offset)
+		}
+		for i, g := range def.Fields {
+			if g.Name == ref.Name && g.Name != "_" {
+				f.SpanOf = i
+			}
+		}
+		if f.SpanOf == -1 {
+			return fmt.Errorf("%w: offset of %s: no field %s", ErrInvalidTagOption, f.Name, ref.Name)
+		}
+	}
+	// span fields can't refer to each other
+	for _, f := range def.Fields {
+		if f.isSpan && f.SpanOf >= 0 && def.Fields[f.SpanOf].isSpan {
+			return fmt.Errorf("%w: offset of %s refers to span field %s", ErrInvalidTagOption, f.Name, def.Fields[f.SpanOf].Name)
+		}
+	}
+	return nil
+}
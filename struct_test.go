@@ -30,3 +30,48 @@ func TestGenerateStructDef(t *testing.T) {
 	}
 
 }
+
+func TestGenerateStructDef_compile(t *testing.T) {
+	type Valid struct {
+		Count uint8
+		Name  string `bin:"size=(2 + 2) * 2"`
+		Data  []byte `bin:"size=%Count * 2,if=%Count > 0"`
+	}
+	def, err := generateStructDef(reflect.TypeOf(Valid{}))
+	if assert.NoError(t, err) {
+		tag := def.Fields[1].Tag
+		p, err := tag.program(tag.Size)
+		if assert.NoError(t, err) {
+			v, ok := p.Const()
+			assert.True(t, ok)
+			assert.Equal(t, int64(8), v)
+		}
+
+		tag = def.Fields[2].Tag
+		p, err = tag.program(tag.If)
+		if assert.NoError(t, err) {
+			_, ok := p.Const()
+			assert.False(t, ok)
+			assert.Equal(t, "bool", p.Type.String())
+		}
+	}
+
+	type Unknown struct {
+		Data []byte `bin:"size=%Count"`
+	}
+	_, err = generateStructDef(reflect.TypeOf(Unknown{}))
+	assert.EqualError(t, err, `binio.Unknown.Data: size: field not found: "Count"`)
+
+	type Mismatch struct {
+		Name string `bin:"size=4"`
+		Data []byte `bin:"size=%Name * 2"`
+	}
+	_, err = generateStructDef(reflect.TypeOf(Mismatch{}))
+	assert.EqualError(t, err, "binio.Mismatch.Data: size: invalid type: string * integer")
+
+	type DivZero struct {
+		Data []byte `bin:"size=4 / (2 - 2)"`
+	}
+	_, err = generateStructDef(reflect.TypeOf(DivZero{}))
+	assert.EqualError(t, err, "binio.DivZero.Data: size: division by zero")
+}
@@ -3,6 +3,7 @@ package binio
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/KlemensWinter/go-binio/expr"
@@ -20,6 +21,9 @@ var (
 		"size",
 		"if",
 		"ptrs",
+		"until",
+		"valid",
+		"offset",
 	}
 
 	// these must be lowercase
@@ -34,17 +38,86 @@ type (
 		If   expr.Expr
 		Ptrs expr.Expr
 
+		// Until ends a slice after the first element for which it is true;
+		// the element is available as $_
+		Until expr.Expr
+
+		// Valid is checked after the field has been decoded, with its
+		// value in $_; decoding fails if it is false
+		Valid expr.Expr
+
+		// Offset names the sibling field whose span is stored in a Span
+		// field; nil for the enclosing struct
+		Offset expr.Expr
+
 		Vars map[string]expr.Expr
 
-		typ string
+		typ    string
+		offset bool
+
+		// the compiled expressions, see compile
+		progs map[expr.Expr]*expr.Program
 	}
 
 	Type byte
 )
 
-func (t *Tag) IsDynArray() bool   { return t.typ == strDynArray }
-func (t *Tag) IsHoleyArray() bool { return t.typ == strHoleyArray }
-func (t *Tag) IsDynString() bool  { return t.typ == strDynString }
+func (t *Tag) IsDynArray() bool   { return t != nil && t.typ == strDynArray }
+func (t *Tag) IsHoleyArray() bool { return t != nil && t.typ == strHoleyArray }
+func (t *Tag) IsDynString() bool  { return t != nil && t.typ == strDynString }
+func (t *Tag) IsOffset() bool     { return t != nil && t.offset }
+
+// sizeExpr returns the size expression of t, which may be nil.
+func (t *Tag) sizeExpr() expr.Expr {
+	if t == nil {
+		return nil
+	}
+	return t.Size
+}
+
+// compile compiles the expressions of t in env.
+func (t *Tag) compile(env *expr.Env) error {
+	progs := make(map[expr.Expr]*expr.Program)
+	add := func(key string, e expr.Expr) error {
+		if e == nil {
+			return nil
+		}
+		p, err := expr.Compile(e, env)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		progs[e] = p
+		return nil
+	}
+	names := t.VarNames()
+	sort.Strings(names)
+	for _, name := range names {
+		if err := add("$"+name, t.Vars[name]); err != nil {
+			return err
+		}
+	}
+	for _, opt := range []struct {
+		key string
+		e   expr.Expr
+	}{{"size", t.Size}, {"if", t.If}, {"ptrs", t.Ptrs}, {"until", t.Until}, {"valid", t.Valid}} {
+		if err := add(opt.key, opt.e); err != nil {
+			return err
+		}
+	}
+	t.progs = progs
+	return nil
+}
+
+// program returns the compiled expression e of t. Expressions of tags
+// which have not been compiled are compiled without type checks.
+func (t *Tag) program(e expr.Expr) (*expr.Program, error) {
+	if p, found := t.progs[e]; found {
+		return p, nil
+	}
+	return expr.Compile(e, identEnv)
+}
+
+func (t *Tag) hasUntil() bool { return t != nil && t.Until != nil }
 
 func (t *Tag) AddVar(name string, value expr.Expr) {
 	if t.Vars == nil {
@@ -68,50 +141,113 @@ func (t *Tag) VarNames() []string {
 	return maps.Keys(t.Vars)
 }
 
+// ParseTag parses the value of a bin struct tag.
+//
+// Options are separated by commas and have the form key=value, or are
+// flags without a value like dynarray (short for type=dynarray) and
+// offset. Commas inside parentheses, brackets and quotes don't separate
+// options, so values like if=startswith(%Name,"ab") or if=%Name == "a,b"
+// work.
+// The value of type may be quoted, like type='dynstring'. All other values
+// are expressions, in which quotes are literals: if=%Name == 'ab' compares
+// with the string ab, and size="4" is a string, not a number.
+// Errors are returned as *TagError with the column of the problem; for
+// syntax errors in expressions the column of the offending character,
+// the *expr.SyntaxError is available with errors.As.
+//
+// valid checks the value of a field after it has been decoded: the value
+// is $_, and decoding fails with ErrInvalidValue if the expression is
+// false, like valid=$_ == "RIFF".
 func ParseTag(str string) (*Tag, error) {
+	opts, err := splitTag(str)
+	if err != nil {
+		return nil, err
+	}
+
 	var tg Tag
-	for _, str := range strings.Split(str, ",") {
-		s := strings.SplitN(str, "=", 2)
-		key := strings.TrimSpace(s[0])
-		value := strings.TrimSpace(s[1])
+	fail := func(col int, err error) (*Tag, error) {
+		return nil, &TagError{Tag: str, Column: col, Err: err}
+	}
+	seen := make(map[string]bool)
+	for _, opt := range opts {
+		key, value := opt.Key, opt.Value
+		if seen[key] {
+			return fail(opt.Col, fmt.Errorf("%w: duplicate option %q", ErrInvalidTagOption, key))
+		}
+		seen[key] = true
+
+		if !opt.HasValue {
+			switch key {
+			case strDynArray, strHoleyArray, strDynString:
+				if tg.typ != "" {
+					return fail(opt.Col, fmt.Errorf("%w: duplicate type %q", ErrInvalidTagOption, key))
+				}
+				tg.typ = key
+			case "offset":
+				tg.offset = true
+			case "type", "size", "if", "ptrs", "until", "valid":
+				return fail(opt.Col, fmt.Errorf("%w: %q needs a value", ErrInvalidTagOption, key))
+			default:
+				if strings.HasPrefix(key, "$") {
+					return fail(opt.Col, fmt.Errorf("%w: variable %q needs a value", ErrInvalidTagOption, key))
+				}
+				return fail(opt.Col, fmt.Errorf("%w: %q", ErrInvalidTagOption, key))
+			}
+			continue
+		}
+
+		// parse parses the value as an expression
+		parse := func(what string) (expr.Expr, error) {
+			e, err := expr.Parse(value)
+			if err != nil {
+				col := opt.ValueCol
+				var serr *expr.SyntaxError
+				if errors.As(err, &serr) {
+					col += serr.Offset
+					err = tagSyntaxError{serr}
+				}
+				return nil, &TagError{Tag: str, Column: col, Err: fmt.Errorf("failed to parse %s: %w", what, err)}
+			}
+			return e, nil
+		}
 
 		switch key {
 		case "type":
-			switch strings.ToLower(value) {
+			typ := strings.ToLower(unquote(value))
+			switch typ {
 			case strDynArray, strHoleyArray, strDynString:
-				tg.typ = strings.ToLower(value)
+				if tg.typ != "" {
+					return fail(opt.Col, fmt.Errorf("%w: duplicate type %q", ErrInvalidTagOption, typ))
+				}
+				tg.typ = typ
 			default:
-				panic(fmt.Errorf("invalid type %q", value))
+				return fail(opt.ValueCol, fmt.Errorf("%w: invalid type %q", ErrInvalidTagOption, value))
 			}
 		case "size":
-			exp, err := expr.Parse(value)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse size: %w", err)
-			}
-			tg.Size = exp
+			tg.Size, err = parse("size")
 		case "if":
-			cond, err := expr.Parse(value)
-			if err != nil {
-				panic(fmt.Errorf("failed to parse condition %q: %w", value, err))
-			}
-			tg.If = cond // TODO: syntax check?
+			tg.If, err = parse("condition")
 		case "ptrs":
-			e, err := expr.Parse(value)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse ptrs: %w", err)
-			}
-			tg.Ptrs = e
+			tg.Ptrs, err = parse("ptrs")
+		case "until":
+			tg.Until, err = parse("until")
+		case "valid":
+			tg.Valid, err = parse("valid")
+		case "offset":
+			tg.offset = true
+			tg.Offset, err = parse("offset")
 		default:
-			if strings.HasPrefix(key, "$") {
-				e, err := expr.Parse(value)
-				if err != nil {
-					return nil, fmt.Errorf("failed to parse variable %q: %w", key, err)
-				}
+			if !strings.HasPrefix(key, "$") || len(key) == 1 {
+				return fail(opt.Col, fmt.Errorf("%w: %q", ErrInvalidTagOption, key))
+			}
+			var e expr.Expr
+			if e, err = parse("variable " + key); err == nil {
 				tg.AddVar(key[1:], e)
-			} else {
-				return nil, fmt.Errorf("%w: %q", ErrInvalidTagOption, key)
 			}
 		}
+		if err != nil {
+			return nil, err
+		}
 	}
 	return &tg, nil
 }
@@ -54,6 +54,80 @@ func TestParseTag_vars(t *testing.T) {
 	}
 }
 
+func TestParseTag_syntax(t *testing.T) {
+	testdata := []struct {
+		In   string
+		Test func(t *testing.T, tag *binio.Tag)
+	}{
+		{"", func(t *testing.T, tag *binio.Tag) { assert.Equal(t, &binio.Tag{}, tag) }},
+		{"dynarray", func(t *testing.T, tag *binio.Tag) { assert.True(t, tag.IsDynArray()) }},
+		{" holeyarray , ptrs=%Ptrs", func(t *testing.T, tag *binio.Tag) {
+			assert.True(t, tag.IsHoleyArray())
+			assert.NotNil(t, tag.Ptrs)
+		}},
+		{"type='dynstring'", func(t *testing.T, tag *binio.Tag) { assert.True(t, tag.IsDynString()) }},
+		{`valid=$_ == "RIFF"`, func(t *testing.T, tag *binio.Tag) {
+			assert.Equal(t, `($_ == "RIFF")`, tag.Valid.String())
+		}},
+		{`type="dynarray",size="4",if=%Name == 'ab'`, func(t *testing.T, tag *binio.Tag) {
+			// only the type is unquoted, other quotes are string literals
+			assert.True(t, tag.IsDynArray())
+			assert.Equal(t, &expr.Const{Value: "4"}, tag.Size)
+			assert.Equal(t, &expr.Const{Value: "ab"}, tag.If.(*expr.BinExpr).Rhs)
+		}},
+		{"offset", func(t *testing.T, tag *binio.Tag) {
+			assert.True(t, tag.IsOffset())
+			assert.Nil(t, tag.Offset)
+		}},
+		{`if=startswith(%A,"ab"),size=%B`, func(t *testing.T, tag *binio.Tag) {
+			assert.Equal(t, `startswith(%A,"ab")`, tag.If.String())
+			assert.NotNil(t, tag.Size)
+		}},
+	}
+
+	for _, tst := range testdata {
+		tag, err := binio.ParseTag(tst.In)
+		if assert.NoError(t, err, tst.In) {
+			tst.Test(t, tag)
+		}
+	}
+}
+
+func TestParseTag_errors(t *testing.T) {
+	testdata := []struct {
+		In     string
+		Column int
+		Msg    string
+	}{
+		{"size=%A,foo", 9, `invalid tag option: "foo"`},
+		{"size", 1, `invalid tag option: "size" needs a value`},
+		{"size=1,valid", 8, `invalid tag option: "valid" needs a value`},
+		{"size=1,,if=1", 8, "invalid tag option: empty option"},
+		{"size=1, =2", 9, "invalid tag option: missing option name"},
+		{"size=1,size=2", 8, `invalid tag option: duplicate option "size"`},
+		{"dynarray,type=dynstring", 10, `invalid tag option: duplicate type "dynstring"`},
+		{"type=foo", 6, `invalid tag option: invalid type "foo"`},
+		{"size=max(%A,%B", 9, `unclosed '('`},
+		{"size=%A)", 8, `unexpected ')'`},
+		{"size=(%A]", 9, `']' does not match '(' in column 6`},
+		{`if=%A == "a,b`, 10, "unterminated quote"},
+		{"size=%A &&", 11, "failed to parse size: unexpected end of expression, expected operand"},
+		{"size=1, if = %A = 2", 17, `failed to parse condition: unexpected "=", expected "=="`},
+		{"ptrs=%A[1 +]", 12, `failed to parse ptrs: unexpected "]", expected operand`},
+		{"$x=%A #", 7, `failed to parse variable $x: invalid token "#"`},
+	}
+
+	for _, tst := range testdata {
+		_, err := binio.ParseTag(tst.In)
+		var tagErr *binio.TagError
+		if !assert.ErrorAs(t, err, &tagErr, tst.In) {
+			continue
+		}
+		assert.Equal(t, tst.Column, tagErr.Column, tst.In)
+		assert.Equal(t, tst.Msg, tagErr.Err.Error(), tst.In)
+	}
+}
+
 /*
 func Test_parseFieldTag(t *testing.T) {
 
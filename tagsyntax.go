@@ -0,0 +1,157 @@
+package binio
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/KlemensWinter/go-binio/expr"
+)
+
+type (
+	// TagError is returned by ParseTag for invalid tags.
+	TagError struct {
+		Tag    string
+		Column int // 1-based byte offset in Tag
+		Err    error
+	}
+
+	// tagOption is one option of a tag
+	tagOption struct {
+		Key      string
+		Value    string
+		HasValue bool
+
+		Col, ValueCol int
+	}
+)
+
+func (err *TagError) Error() string {
+	return fmt.Sprintf("column %d: %v", err.Column, err.Err)
+}
+
+func (err *TagError) Unwrap() error {
+	return err.Err
+}
+
+// tagSyntaxError is a syntax error in an expression of a tag; its offset
+// is part of the column of the TagError.
+type tagSyntaxError struct {
+	err *expr.SyntaxError
+}
+
+func (err tagSyntaxError) Error() string { return err.err.Reason() }
+func (err tagSyntaxError) Unwrap() error { return err.err }
+
+var closing = map[byte]byte{'(': ')', '[': ']', '{': '}'}
+
+// splitTag splits a tag into options at commas outside of parentheses,
+// brackets and quotes. Quotes may contain backslash escapes.
+func splitTag(str string) ([]tagOption, error) {
+	var (
+		opts   []tagOption
+		start  = 0   // start of the current option
+		eq     = -1  // the first = of the current option
+		stack  []int // positions of open brackets
+		quote  byte  // the current quote or 0
+		qstart int   // position of the quote
+	)
+	fail := func(pos int, format string, args ...any) ([]tagOption, error) {
+		return nil, &TagError{Tag: str, Column: pos + 1, Err: fmt.Errorf(format, args...)}
+	}
+	end := func(pos int) error {
+		opt, err := newTagOption(str, start, eq, pos)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, opt)
+		start, eq = pos+1, -1
+		return nil
+	}
+
+	if strings.TrimSpace(str) == "" {
+		return nil, nil
+	}
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if quote != 0 {
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote, qstart = c, i
+		case '(', '[', '{':
+			stack = append(stack, i)
+		case ')', ']', '}':
+			if len(stack) == 0 {
+				return fail(i, "unexpected %q", c)
+			}
+			open := stack[len(stack)-1]
+			if closing[str[open]] != c {
+				return fail(i, "%q does not match %q in column %d", c, str[open], open+1)
+			}
+			stack = stack[:len(stack)-1]
+		case '=':
+			if eq < 0 && len(stack) == 0 {
+				eq = i
+			}
+		case ',':
+			if len(stack) == 0 {
+				if err := end(i); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	switch {
+	case quote != 0:
+		return fail(qstart, "unterminated quote")
+	case len(stack) > 0:
+		open := stack[len(stack)-1]
+		return fail(open, "unclosed %q", str[open])
+	}
+	if err := end(len(str)); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// newTagOption creates the option str[start:end] with the = at eq.
+func newTagOption(str string, start, eq, end int) (tagOption, error) {
+	// skip leading whitespace for the column
+	for start < end && (str[start] == ' ' || str[start] == '\t') {
+		start++
+	}
+	opt := tagOption{Col: start + 1}
+	if eq < 0 {
+		opt.Key = strings.TrimSpace(str[start:end])
+	} else {
+		opt.Key = strings.TrimSpace(str[start:eq])
+		opt.HasValue = true
+		opt.ValueCol = eq + 2
+		raw := str[eq+1 : end]
+		opt.Value = strings.TrimSpace(raw)
+		opt.ValueCol += len(raw) - len(strings.TrimLeft(raw, " \t"))
+	}
+	if opt.Key == "" {
+		msg := "empty option"
+		if opt.HasValue {
+			msg = "missing option name"
+		}
+		return opt, &TagError{Tag: str, Column: start + 1, Err: fmt.Errorf("%w: %s", ErrInvalidTagOption, msg)}
+	}
+	return opt, nil
+}
+
+// unquote removes quotes around a tag value.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
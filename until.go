@@ -0,0 +1,72 @@
+package binio
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/KlemensWinter/go-binio/expr"
+)
+
+// untilSlice decodes elements until the until expression of the current
+// field is true for the last decoded element, which is available as $_.
+func (dec *Decoder) untilSlice(v reflect.Value, fields fieldFunc) error {
+	sl := reflect.MakeSlice(v.Type(), 0, 0)
+	trace := dec.traceElems(v.Type())
+	defer dec.saveElem()()
+	for {
+		if sl.Len() >= maxArraySize {
+			return fmt.Errorf("array to big! max=%d", maxArraySize)
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		dec.setElem(sl.Len(), -1, elemValue(sl, sl.Len()-1))
+		start := dec.enterElem(trace, sl.Len())
+		if err := dec.decodeValue(elem); err != nil {
+			return err
+		}
+		dec.leaveElem(trace, start, elem)
+		sl = reflect.Append(sl, elem)
+
+		done, err := dec.until(fieldValue(elem), fields)
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+	v.Set(sl)
+	return nil
+}
+
+// until binds last to $_ and evaluates the until expression of the
+// current field.
+func (dec *Decoder) until(last any, fields fieldFunc) (bool, error) {
+	cur := dec.current()
+	cur.Set("_", last)
+	res, err := dec.eval(cur.Field.Tag, cur.Field.Tag.Until, fields)
+	if err != nil {
+		return false, err
+	}
+	return expr.Bool(res), nil
+}
+
+// valid binds v to $_ and checks it with the valid expression of the
+// current field. Fields which are not decoded are not checked.
+func (dec *Decoder) valid(v any, fields fieldFunc) error {
+	cur := dec.current()
+	if cur.Field.Tag == nil || cur.Field.Tag.Valid == nil {
+		return nil
+	}
+	if cur.Condition != nil && !expr.Bool(cur.Condition) {
+		return nil
+	}
+	cur.Set("_", v)
+	res, err := dec.eval(cur.Field.Tag, cur.Field.Tag.Valid, fields)
+	if err != nil {
+		return err
+	}
+	if !expr.Bool(res) {
+		return fmt.Errorf("%w: %s", ErrInvalidValue, cur.Field.Tag.Valid)
+	}
+	return nil
+}
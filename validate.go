@@ -0,0 +1,254 @@
+package binio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/KlemensWinter/go-binio/expr"
+)
+
+var (
+	ErrInvalidExpr = errors.New("invalid expression")
+)
+
+type validator struct {
+	errs     []error
+	seen     map[string]bool
+	visiting []reflect.Type
+}
+
+// Validate checks the tags of T and all types it references and returns
+// all problems found, joined with errors.Join:
+//
+//   - tag syntax and unknown options
+//   - fields that need a size, like strings
+//   - references to fields that don't exist or come later
+//   - the types of expressions: size must be an integer, if, until and
+//     valid must be bool or a field, ptrs must be a slice
+//   - variables that are not defined by the tag of the field or of an
+//     enclosing field
+//
+// Types are validated with their own tags only; expressions of values
+// passed at decode time can't be checked.
+func Validate[T any]() error {
+	var v T
+	return ValidateType(reflect.TypeOf(&v).Elem())
+}
+
+// ValidateType is like Validate for typ.
+func ValidateType(typ reflect.Type) error {
+	vd := &validator{seen: make(map[string]bool)}
+	vd.typ(typ, nil)
+	return errors.Join(vd.errs...)
+}
+
+func (vd *validator) problem(typ reflect.Type, field string, err error) {
+	if field != "" {
+		err = fmt.Errorf("%s.%s: %w", typ, field, err)
+	} else {
+		err = fmt.Errorf("%s: %w", typ, err)
+	}
+	if !vd.seen[err.Error()] {
+		vd.seen[err.Error()] = true
+		vd.errs = append(vd.errs, err)
+	}
+}
+
+// typ validates the struct types in typ; vars are the variables defined
+// by enclosing fields.
+func (vd *validator) typ(typ reflect.Type, vars map[string]bool) {
+	typ = exportElem(typ)
+	if typ.Kind() != reflect.Struct || typ == spanType {
+		return
+	}
+	for _, t := range vd.visiting {
+		if t == typ {
+			return
+		}
+	}
+	vd.visiting = append(vd.visiting, typ)
+	defer func() { vd.visiting = vd.visiting[:len(vd.visiting)-1] }()
+
+	def := &structDef{Name: typ.String()}
+	defer func() {
+		if err := def.resolveSpans(); err != nil {
+			vd.problem(typ, "", err)
+		}
+	}()
+
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		f := &field{Struct: def, Name: sf.Name, Typ: sf.Type}
+		def.Fields = append(def.Fields, f)
+		if str, found := sf.Tag.Lookup(tagName); found {
+			tg, err := ParseTag(str)
+			if err != nil {
+				vd.problem(typ, sf.Name, err)
+				continue
+			}
+			f.Tag = tg
+		}
+		if err := checkField(f); err != nil {
+			vd.problem(typ, sf.Name, err)
+		} else if f.Typ.Kind() == reflect.Slice && f.Tag.sizeExpr() == nil && !f.Tag.hasUntil() && !f.Tag.IsHoleyArray() {
+			vd.problem(typ, sf.Name, ErrMissingSize)
+		}
+		if f.Tag == nil {
+			vd.typ(sf.Type, elemScope(sf.Type, vars))
+			continue
+		}
+
+		scope := make(map[string]bool, len(vars)+len(f.Tag.Vars))
+		for name := range vars {
+			scope[name] = true
+		}
+		for name := range f.Tag.Vars {
+			scope[name] = true
+		}
+		for _, err := range vd.tag(typ, i, f, scope) {
+			vd.problem(typ, sf.Name, err)
+		}
+		vd.typ(sf.Type, elemScope(sf.Type, scope))
+	}
+}
+
+// elemVars are the variables defined for the elements of slices and arrays.
+var elemVars = []string{"_index", "_count", "_last"}
+
+// elemScope returns a copy of vars with the element variables if typ is a
+// slice or array.
+func elemScope(typ reflect.Type, vars map[string]bool) map[string]bool {
+	scope := make(map[string]bool, len(vars)+len(elemVars))
+	for name := range vars {
+		scope[name] = true
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		for _, name := range elemVars {
+			scope[name] = true
+		}
+	}
+	return scope
+}
+
+// tag checks the expressions of the tag of the i-th field of typ.
+func (vd *validator) tag(typ reflect.Type, i int, f *field, vars map[string]bool) []error {
+	var (
+		errs []error
+		tag  = f.Tag
+	)
+	env := structEnv(typ, []reflect.Type{typ})
+	// check checks e, which may refer to the first decoded fields of typ
+	check := func(key string, e expr.Expr, vars map[string]bool, decoded int, want func(expr.Type, expr.Expr) bool) {
+		if e == nil {
+			return
+		}
+		ok := true
+		expr.Inspect(e, func(e expr.Expr) bool {
+			switch e := e.(type) {
+			case *expr.Field:
+				if isScopeRef(e.Name) {
+					break
+				}
+				j := fieldIndex(typ, e.Name)
+				switch {
+				case j < 0:
+					errs = append(errs, fmt.Errorf("%s: %w: %s", key, expr.ErrFieldNotFound, e))
+					ok = false
+				case j >= decoded:
+					errs = append(errs, fmt.Errorf("%s: %w: %s is not decoded yet", key, ErrInvalidExpr, e))
+					ok = false
+				}
+			case *expr.Var:
+				if !vars[e.Name] {
+					errs = append(errs, fmt.Errorf("%s: %w: %s", key, expr.ErrVarNotDefined, e))
+					ok = false
+				}
+			case *expr.Ident:
+				if _, found := identValue(e.Name); !found {
+					errs = append(errs, fmt.Errorf("%s: %w: %s", key, expr.ErrIdentNotFound, e))
+					ok = false
+				}
+			case *expr.Call:
+				_, builtin := expr.Builtin(e.Name)
+				if _, found := lookupFunc(e.Name); !builtin && !found {
+					errs = append(errs, fmt.Errorf("%s: %w: %s", key, expr.ErrFuncNotFound, e.Name))
+					ok = false
+				}
+			}
+			return true
+		})
+		if !ok {
+			return
+		}
+		// the types are checked like the decoder compiles the tags
+		p, err := expr.Compile(e, env)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			return
+		}
+		if want != nil && !want(p.Type, e) {
+			errs = append(errs, fmt.Errorf("%s: %w: %s has type %s", key, ErrInvalidExpr, e, p.Type))
+		}
+	}
+
+	isInt := func(t expr.Type, e expr.Expr) bool { return t == expr.TypeInt || t == expr.TypeUnknown }
+	isBoolish := func(t expr.Type, e expr.Expr) bool {
+		switch e.(type) {
+		case *expr.Field, *expr.Var, *expr.Selector, *expr.Index, *expr.BinExpr:
+			return true // the values of fields are true if they are not zero
+		}
+		return t == expr.TypeBool || t == expr.TypeUnknown
+	}
+	// slices other than byte slices have no static type
+	isList := func(t expr.Type, e expr.Expr) bool { return t == expr.TypeUnknown }
+
+	names := tag.VarNames()
+	sort.Strings(names)
+	for _, name := range names {
+		check("$"+name, tag.Vars[name], vars, i, nil)
+	}
+	if tag.IsDynArray() || tag.IsDynString() {
+		if tag.Size != nil && IntSize(tag.Size.String()) == -1 {
+			errs = append(errs, fmt.Errorf("size: %w: %s is not an integer type", ErrInvalidExpr, tag.Size))
+		}
+	} else {
+		check("size", tag.Size, vars, i, isInt)
+	}
+	check("if", tag.If, vars, i, isBoolish)
+	check("ptrs", tag.Ptrs, vars, i, isList)
+	if tag.Until != nil {
+		until := elemScope(f.Typ, vars)
+		until["_"] = true
+		check("until", tag.Until, until, i, isBoolish)
+	}
+	if tag.Valid != nil {
+		valid := make(map[string]bool, len(vars)+1)
+		for name := range vars {
+			valid[name] = true
+		}
+		valid["_"] = true
+		// valid is checked after the field itself is decoded
+		check("valid", tag.Valid, valid, i+1, isBoolish)
+	}
+	return errs
+}
+
+// fieldIndex returns the index of the field name of typ or -1. Promoted
+// fields of embedded structs are found like by the decoder, with the index
+// of the embedded struct.
+func fieldIndex(typ reflect.Type, name string) int {
+	if f, found := typ.FieldByName(name); found {
+		return f.Index[0]
+	}
+	return -1
+}
+
+// isScopeRef reports whether %name refers to an enclosing struct.
+func isScopeRef(name string) bool {
+	return name == "_parent" || name == "_root"
+}
@@ -0,0 +1,100 @@
+package binio_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/KlemensWinter/go-binio"
+	"github.com/KlemensWinter/go-binio/expr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	type Entry struct {
+		Len  uint8
+		Name string `bin:"size=%Len"`
+		Data []byte `bin:"size=$total"`
+		Ext  []byte `bin:"size=%_parent.Header.Sizes[1]"`
+		Pad  []byte `bin:"size=$_index"`
+	}
+	type Header struct {
+		Sizes [2]uint8
+	}
+	type Base struct {
+		Kind uint8
+	}
+	type Valid struct {
+		Base
+		Magic   [4]byte
+		Opt     uint8 `bin:"if=%Magic && %Kind"`
+		Header  Header
+		Count   uint16 `bin:"if=%Header.Sizes[0] > 0"`
+		Flags   uint8
+		Entries []Entry `bin:"size=%Count,$total=%Flags"`
+		Ext     uint32  `bin:"if=%Flags"`
+		Tail    []uint8 `bin:"until=$_ == 0"`
+		Names   []uint8 `bin:"type=dynarray,size=uint16"`
+		Scaled  []uint8 `bin:"size=(%Count + 1) * 2,if=%Flags & 1"`
+		Version uint8   `bin:"valid=$_ > 0 && $_ <= %Flags && %Version < 10"`
+	}
+	assert.NoError(t, binio.Validate[Valid]())
+	assert.NoError(t, binio.Validate[uint32]())
+}
+
+func TestValidate_errors(t *testing.T) {
+	type Inner struct {
+		Data []byte `bin:"size=$missing"`
+		Pad  []byte `bin:"size=$_index"`
+	}
+	type Invalid struct {
+		Name   string
+		Data   []byte `bin:"size=%Count"`
+		Count  uint8
+		Flag   uint8   `bin:"if=123"`
+		Bad    uint8   `bin:"foo=1"`
+		Values []uint8 `bin:"size=%Name"`
+		Holes  []uint8 `bin:"type=holeyarray,ptrs=%Count"`
+		Nested Inner
+		Gone   []uint8 `bin:"size=%Gone2"`
+		Lower  []uint8 `bin:"size=lower(%Name)"`
+		Func   []uint8 `bin:"size=foo(%Name)"`
+		Func2  []uint8 `bin:"size=foo(%Name) == 1"`
+		Member []uint8 `bin:"size=%Nested.Missing"`
+		Elem   []uint8 `bin:"size=%Values[0],if=%Nested.Data"`
+		Mixed  []uint8 `bin:"size=%Name - 1"`
+		Cond   []uint8 `bin:"size=%Count ? 'ab' : 'bc'"`
+		Concat []uint8 `bin:"size=%Name + 'xy'"`
+		Check  uint8   `bin:"valid=$_ + $missing"`
+	}
+
+	err := binio.Validate[Invalid]()
+	if !assert.Error(t, err) {
+		return
+	}
+	assert.ErrorIs(t, err, binio.ErrMissingTag)
+	assert.ErrorIs(t, err, binio.ErrInvalidTagOption)
+	assert.ErrorIs(t, err, binio.ErrInvalidExpr)
+	assert.ErrorIs(t, err, expr.ErrVarNotDefined)
+	assert.ErrorIs(t, err, expr.ErrFieldNotFound)
+	assert.ErrorIs(t, err, expr.ErrFuncNotFound)
+
+	assert.Equal(t, []string{
+		"binio_test.Invalid.Name: missing field tag",
+		"binio_test.Invalid.Data: size: invalid expression: %Count is not decoded yet",
+		"binio_test.Invalid.Flag: if: invalid expression: 123 has type integer",
+		`binio_test.Invalid.Bad: column 1: invalid tag option: "foo"`,
+		"binio_test.Invalid.Values: size: invalid expression: %Name has type string",
+		"binio_test.Invalid.Holes: ptrs: invalid expression: %Count has type integer",
+		"binio_test.Inner.Data: size: variable not defined: $missing",
+		"binio_test.Inner.Pad: size: variable not defined: $_index",
+		`binio_test.Invalid.Gone: size: field not found: %Gone2`,
+		"binio_test.Invalid.Lower: size: invalid expression: lower(%Name) has type string",
+		"binio_test.Invalid.Func: size: unknown function: foo",
+		"binio_test.Invalid.Func2: size: unknown function: foo",
+		"binio_test.Invalid.Member: size: field not found: %Nested.Missing",
+		"binio_test.Invalid.Mixed: size: invalid type: string - integer",
+		`binio_test.Invalid.Cond: size: invalid expression: (%Count ? "ab" : "bc") has type string`,
+		`binio_test.Invalid.Concat: size: invalid expression: (%Name + "xy") has type string`,
+		"binio_test.Invalid.Check: valid: variable not defined: $missing",
+	}, strings.Split(err.Error(), "\n"))
+}